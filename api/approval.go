@@ -0,0 +1,83 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 审批列表
+// @Description 审批列表接口, 可按状态过滤
+// @Security ApiKeyAuth
+// @Tags 审批
+// @Accept json
+// @Produce json
+// @Param state query string false "pending|approved|rejected|timeout"
+// @Success 200 {object} model.ApiRespone "获取审批列表成功"
+// @Failure 500 {object} model.ApiRespone "获取审批列表失败"
+// @Router /approval/list [get]
+func ApprovalLists(ctx *gin.Context) {
+	approvals, err := service.ApprovalLists(ctx.Query("state"))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	visible := make([]model.Approval, 0, len(approvals))
+	for _, approval := range approvals {
+		if core.Can(ctx, core.PermView, approval.TaskName) {
+			visible = append(visible, approval)
+		}
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取审批列表成功", Data: visible})
+}
+
+// @Summary 通过审批
+// @Description 通过审批后构建继续执行
+// @Security ApiKeyAuth
+// @Tags 审批
+// @Accept json
+// @Produce json
+// @Param id path string true "审批ID"
+// @Success 200 {object} model.ApiRespone "审批通过"
+// @Failure 500 {object} model.ApiRespone "审批失败"
+// @Router /approval/approve/{id} [post]
+func ApproveBuild(ctx *gin.Context) {
+	decideApproval(ctx, true, "审批通过")
+}
+
+// @Summary 拒绝审批
+// @Description 拒绝审批后构建终止
+// @Security ApiKeyAuth
+// @Tags 审批
+// @Accept json
+// @Produce json
+// @Param id path string true "审批ID"
+// @Success 200 {object} model.ApiRespone "审批已拒绝"
+// @Failure 500 {object} model.ApiRespone "审批失败"
+// @Router /approval/reject/{id} [post]
+func RejectBuild(ctx *gin.Context) {
+	decideApproval(ctx, false, "审批已拒绝")
+}
+
+func decideApproval(ctx *gin.Context, approve bool, message string) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := core.Tp.DecideApproval(id, ctx.GetString(core.UsernameKey), approve); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: message})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 审计日志列表
+// @Description 按执行者、动作、对象和时间范围分页查询审计日志
+// @Security ApiKeyAuth
+// @Tags 审计
+// @Produce json
+// @Param actor query string false "执行者"
+// @Param action query string false "动作, 模糊匹配"
+// @Param target query string false "对象, 模糊匹配"
+// @Param since query string false "开始时间(RFC3339)"
+// @Param until query string false "结束时间(RFC3339)"
+// @Param page query int false "页码"
+// @Param size query int false "每页条数"
+// @Success 200 {object} model.ApiRespone "获取审计日志成功"
+// @Failure 500 {object} model.ApiRespone "获取审计日志失败"
+// @Router /audit/list [get]
+func AuditLists(ctx *gin.Context) {
+	var query model.AuditQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	page, err := service.AuditLists(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取审计日志成功", Data: page})
+}
+
+// @Summary 导出审计日志
+// @Description 以JSON lines格式导出审计日志, 过滤条件同列表接口
+// @Security ApiKeyAuth
+// @Tags 审计
+// @Produce application/x-ndjson
+// @Param actor query string false "执行者"
+// @Param action query string false "动作, 模糊匹配"
+// @Param target query string false "对象, 模糊匹配"
+// @Param since query string false "开始时间(RFC3339)"
+// @Param until query string false "结束时间(RFC3339)"
+// @Success 200 {string} string "每行一条JSON"
+// @Failure 500 {object} model.ApiRespone "导出审计日志失败"
+// @Router /audit/export [get]
+func AuditExport(ctx *gin.Context) {
+	var query model.AuditQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("audit-%s.jsonl", time.Now().Format("20060102150405"))
+	ctx.Header("Content-Type", "application/x-ndjson")
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	ctx.Status(http.StatusOK)
+	// 响应已开始写出, 出错时只能中断
+	if err := service.AuditExport(query, ctx.Writer); err != nil {
+		ctx.Abort()
+	}
+}
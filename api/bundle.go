@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 导出任务
+// @Description 导出单个任务、一个目录或全部任务(流水线、描述、触发规则和设置), 只包含凭据引用不包含凭据值. 只导出当前用户有查看权限的任务
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Produce application/x-yaml
+// @Param name query string false "任务名"
+// @Param folder query string false "目录"
+// @Param format query string false "yaml(默认)或tar(tar.gz)"
+// @Success 200 {string} string "导出文件"
+// @Failure 500 {object} model.ApiRespone "导出任务失败"
+// @Router /task/export [get]
+func ExportTasks(ctx *gin.Context) {
+	var query model.ExportQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	bundle, err := service.ExportBundle(query, core.Checker(ctx, core.PermView))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	var buf bytes.Buffer
+	if err := service.WriteBundle(&buf, bundle, query.Format); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: service.ErrExportTasks.Error()})
+		return
+	}
+	contentType, ext := "application/x-yaml", "yaml"
+	if query.Format == model.BundleFormatTar {
+		contentType, ext = "application/gzip", "tar.gz"
+	}
+	filename := fmt.Sprintf("gookins-tasks-%s.%s", time.Now().Format("20060102150405"), ext)
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	ctx.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// @Summary 导入任务
+// @Description 导入导出的YAML或tar.gz文件. conflict为同名任务的处理方式: skip(默认)、overwrite或rename; dry_run为true时只检查并返回报告, 不写入
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "导出文件"
+// @Param dry_run query bool false "试运行"
+// @Param conflict query string false "skip、overwrite或rename"
+// @Success 200 {object} model.ApiRespone "导入报告"
+// @Failure 500 {object} model.ApiRespone "导入任务失败"
+// @Router /task/import [post]
+func ImportTasks(ctx *gin.Context) {
+	var query model.ImportQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "bundle:"+header.Filename)
+	file, err := header.Open()
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	defer file.Close()
+	bundle, err := service.ReadBundle(file)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	report, err := service.ImportBundle(bundle, query, ctx.GetString(core.UsernameKey), core.Checker(ctx, core.PermEdit), core.Checker(ctx, core.PermRun))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditDetail(ctx, service.ImportSummary(report))
+	message := "导入任务成功"
+	if report.DryRun {
+		message = "试运行完成, 未写入任何修改"
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: message, Data: report})
+}
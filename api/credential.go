@@ -0,0 +1,110 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 创建凭据
+// @Description 凭据创建接口, 内容加密存储
+// @Security ApiKeyAuth
+// @Tags 凭据
+// @Accept json
+// @Produce json
+// @Param credential body model.CredentialForm true "创建凭据请求参数"
+// @Success 200 {object} model.ApiRespone "创建凭据成功"
+// @Failure 500 {object} model.ApiRespone "创建凭据失败"
+// @Router /credential/add [post]
+func CreateCredential(ctx *gin.Context) {
+	var credentialForm model.CredentialForm
+	if err := ctx.ShouldBindJSON(&credentialForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "credential:"+credentialForm.CredId)
+	if err := service.CreateCredential(credentialForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "创建凭据成功"})
+}
+
+// @Summary 删除凭据
+// @Description 凭据删除接口
+// @Security ApiKeyAuth
+// @Tags 凭据
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} model.ApiRespone "删除凭据成功"
+// @Failure 500 {object} model.ApiRespone "删除凭据失败"
+// @Router /credential/del/{id} [delete]
+func DeleteCredential(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := service.DeleteCredential(id); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "删除凭据成功"})
+}
+
+// @Summary 更新凭据
+// @Description 凭据更新接口
+// @Security ApiKeyAuth
+// @Tags 凭据
+// @Accept json
+// @Produce json
+// @Param credential body model.CredentialForm true "更新凭据请求参数"
+// @Success 200 {object} model.ApiRespone "更新凭据成功"
+// @Failure 500 {object} model.ApiRespone "更新凭据失败"
+// @Router /credential/upt [put]
+func UpdateCredential(ctx *gin.Context) {
+	var credentialForm model.CredentialForm
+	if err := ctx.ShouldBindJSON(&credentialForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "credential:"+credentialForm.CredId)
+	if err := service.UpdateCredential(credentialForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "更新凭据成功"})
+}
+
+// @Summary 凭据列表
+// @Description 凭据列表接口, 不返回凭据内容
+// @Security ApiKeyAuth
+// @Tags 凭据
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "获取凭据列表成功"
+// @Failure 500 {object} model.ApiRespone "获取凭据列表失败"
+// @Router /credential/list [get]
+func CredentialLists(ctx *gin.Context) {
+	credentials, err := service.CredentialLists()
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取凭据列表成功", Data: credentials})
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 目录列表
+// @Description 列出目录下当前用户有查看权限的直接子目录, path为空时列出顶层目录
+// @Security ApiKeyAuth
+// @Tags 目录
+// @Produce json
+// @Param path query string false "上级目录"
+// @Success 200 {object} model.ApiRespone "获取目录列表成功"
+// @Failure 500 {object} model.ApiRespone "获取目录列表失败"
+// @Router /folder/list [get]
+func FolderLists(ctx *gin.Context) {
+	folders, err := service.FolderLists(ctx.Query("path"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	// 与导出一样按目录的权限检查对象过滤
+	canView := core.Checker(ctx, core.PermView)
+	visible := make([]model.Folder, 0, len(folders))
+	for _, folder := range folders {
+		if canView(core.FolderScope(folder.Path)) {
+			visible = append(visible, folder)
+		}
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取目录列表成功", Data: visible})
+}
+
+// @Summary 创建目录
+// @Description 创建目录, 上级目录必须已存在
+// @Security ApiKeyAuth
+// @Tags 目录
+// @Accept json
+// @Produce json
+// @Param folder body model.FolderForm true "创建目录请求参数"
+// @Success 200 {object} model.ApiRespone "创建目录成功"
+// @Failure 500 {object} model.ApiRespone "创建目录失败"
+// @Router /folder/add [post]
+func CreateFolder(ctx *gin.Context) {
+	var folderForm model.FolderForm
+	if err := ctx.ShouldBindJSON(&folderForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "folder:"+folderForm.Path)
+	if err := service.CreateFolder(folderForm); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "创建目录成功"})
+}
+
+// @Summary 更新目录
+// @Description 更新目录的描述、继承的环境变量和执行节点标签
+// @Security ApiKeyAuth
+// @Tags 目录
+// @Accept json
+// @Produce json
+// @Param folder body model.FolderForm true "更新目录请求参数"
+// @Success 200 {object} model.ApiRespone "更新目录成功"
+// @Failure 500 {object} model.ApiRespone "更新目录失败"
+// @Router /folder/upt [put]
+func UpdateFolder(ctx *gin.Context) {
+	var folderForm model.FolderForm
+	if err := ctx.ShouldBindJSON(&folderForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "folder:"+folderForm.Path)
+	if err := service.UpdateFolder(folderForm); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "更新目录成功"})
+}
+
+// @Summary 删除目录
+// @Description 删除空目录
+// @Security ApiKeyAuth
+// @Tags 目录
+// @Produce json
+// @Param path path string true "目录"
+// @Success 200 {object} model.ApiRespone "删除目录成功"
+// @Failure 500 {object} model.ApiRespone "删除目录失败"
+// @Router /folder/del/{path} [delete]
+func DeleteFolder(ctx *gin.Context) {
+	path := strings.TrimPrefix(ctx.Param("path"), "/")
+	core.SetAuditTarget(ctx, "folder:"+path)
+	if err := service.DeleteFolder(path); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "删除目录成功"})
+}
+
+// @Summary 移动目录
+// @Description 移动或重命名目录, 目录下的任务、构建记录、凭据和角色范围随之更新, 其他任务保存的流水线中的trigger目标也随之改写
+// @Security ApiKeyAuth
+// @Tags 目录
+// @Accept json
+// @Produce json
+// @Param move body model.MoveForm true "原目录和新目录"
+// @Success 200 {object} model.ApiRespone "移动目录成功"
+// @Failure 500 {object} model.ApiRespone "移动目录失败"
+// @Router /folder/move [post]
+func MoveFolder(ctx *gin.Context) {
+	var moveForm model.MoveForm
+	if err := ctx.ShouldBindJSON(&moveForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "folder:"+moveForm.From+" -> "+moveForm.To)
+	if err := service.MoveFolder(moveForm.From, moveForm.To); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "移动目录成功"})
+}
+
+// @Summary 移动任务
+// @Description 移动或重命名任务, 构建记录、修订版本、凭据和角色范围随之更新, 其他任务保存的流水线中的trigger目标也随之改写
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Accept json
+// @Produce json
+// @Param move body model.MoveForm true "原任务名和新任务名"
+// @Success 200 {object} model.ApiRespone "移动任务成功"
+// @Failure 500 {object} model.ApiRespone "移动任务失败"
+// @Router /task/move [post]
+func MoveTask(ctx *gin.Context) {
+	var moveForm model.MoveForm
+	if err := ctx.ShouldBindJSON(&moveForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "task:"+moveForm.From+" -> "+moveForm.To)
+	if err := service.MoveTask(moveForm.From, moveForm.To); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "移动任务成功"})
+}
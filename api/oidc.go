@@ -0,0 +1,70 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary OIDC登录
+// @Description 跳转到OIDC身份提供方进行授权码+PKCE登录
+// @Tags 用户
+// @Success 302 "跳转到IdP"
+// @Failure 500 {object} model.ApiRespone "未启用或IdP不可用"
+// @Router /oidc/login [get]
+func OidcLogin(ctx *gin.Context) {
+	authUrl, err := core.OidcAuthUrl(ctx.Request.Context())
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.Redirect(http.StatusFound, authUrl)
+}
+
+// @Summary OIDC回调
+// @Description IdP授权后的回调, 校验身份令牌后创建登录会话
+// @Tags 用户
+// @Produce json
+// @Param code query string true "授权码"
+// @Param state query string true "登录状态"
+// @Success 200 {object} model.LoginRespon "成功返回Token"
+// @Failure 401 {object} model.ApiRespone "登录失败"
+// @Router /oidc/callback [get]
+func OidcCallback(ctx *gin.Context) {
+	if errMsg := ctx.Query("error"); errMsg != "" {
+		ctx.JSON(http.StatusUnauthorized, model.ApiRespone{Code: 401, Message: errMsg + " " + ctx.Query("error_description")})
+		return
+	}
+	identity, err := core.OidcCallback(ctx.Request.Context(), ctx.Query("code"), ctx.Query("state"))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusUnauthorized, model.ApiRespone{Code: 401, Message: err.Error()})
+		return
+	}
+	username, err := service.OidcSign(identity)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusUnauthorized, model.ApiRespone{Code: 401, Message: err.Error()})
+		return
+	}
+	token, refresh, err := core.CreateSession(username)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	// 前端通过地址片段取得token, 片段不会发送到服务端日志
+	if core.Config.Oidc.UiRedirect != "" {
+		fragment := url.Values{"token": {token}, "refresh_token": {refresh}}
+		ctx.Redirect(http.StatusFound, core.Config.Oidc.UiRedirect+"#"+fragment.Encode())
+		return
+	}
+	ctx.JSON(http.StatusOK, model.LoginRespon{Code: 200, Message: "登陆成功", Token: token, RefreshToken: refresh})
+}
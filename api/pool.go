@@ -0,0 +1,173 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gookins/core"
+	"gookins/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 任务池概览
+// @Description 任务池策略、容量、排队和运行中的任务, 只列出当前用户有查看权限的任务
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone{data=model.PoolInfo} "获取任务池信息成功"
+// @Router /pool [get]
+func PoolInfo(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取任务池信息成功", Data: visibleSnapshot(ctx)})
+}
+
+// 任务池快照中只保留当前用户有查看权限的任务
+func visibleSnapshot(ctx *gin.Context) model.PoolInfo {
+	info := core.Tp.Snapshot()
+	canView := core.Checker(ctx, core.PermView)
+	queued := make([]model.QueuedJobInfo, 0, len(info.Queued))
+	for _, job := range info.Queued {
+		if canView(job.Name) {
+			queued = append(queued, job)
+		}
+	}
+	running := make([]model.RunningJobInfo, 0, len(info.Running))
+	for _, job := range info.Running {
+		if canView(job.Name) {
+			running = append(running, job)
+		}
+	}
+	info.Queued, info.Running = queued, running
+	return info
+}
+
+// @Summary 排队任务列表
+// @Description 排队任务及其位置和等待时间, 只列出当前用户有查看权限的任务
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone{data=[]model.QueuedJobInfo} "获取排队任务成功"
+// @Router /pool/queue [get]
+func PoolQueue(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取排队任务成功", Data: visibleSnapshot(ctx).Queued})
+}
+
+// @Summary 运行任务列表
+// @Description 每个worker上正在运行的任务及已运行时间, 只列出当前用户有查看权限的任务
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone{data=[]model.RunningJobInfo} "获取运行任务成功"
+// @Router /pool/workers [get]
+func PoolWorkers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取运行任务成功", Data: visibleSnapshot(ctx).Running})
+}
+
+// @Summary 移除排队任务
+// @Description 在任务开始执行前将其从队列中移除
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Param id path string true "排队任务ID"
+// @Success 200 {object} model.ApiRespone "移除排队任务成功"
+// @Failure 500 {object} model.ApiRespone "移除排队任务失败"
+// @Router /pool/queue/{id} [delete]
+func RemoveQueued(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if !core.Tp.RemoveQueued(id) {
+		slog.Error("排队任务不存在")
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: "排队任务不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "移除排队任务成功"})
+}
+
+// @Summary 暂停任务池
+// @Description 暂停出队, 队列仍接收新任务, 状态在重启后保留
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "暂停任务池成功"
+// @Failure 500 {object} model.ApiRespone "暂停任务池失败"
+// @Router /pool/pause [post]
+func PausePool(ctx *gin.Context) {
+	if err := core.Tp.Pause(); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "暂停任务池成功"})
+}
+
+// @Summary 恢复任务池
+// @Description 恢复出队并重新接收新任务
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "恢复任务池成功"
+// @Failure 500 {object} model.ApiRespone "恢复任务池失败"
+// @Router /pool/resume [post]
+func ResumePool(ctx *gin.Context) {
+	if err := core.Tp.Resume(); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "恢复任务池成功"})
+}
+
+// @Summary 排空任务池
+// @Description 正在运行的任务执行完毕, 不再接收和启动新任务, 已排队的构建保持pending直到恢复任务池
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "排空任务池成功"
+// @Failure 500 {object} model.ApiRespone "排空任务池失败"
+// @Router /pool/drain [post]
+func DrainPool(ctx *gin.Context) {
+	if err := core.Tp.Drain(); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "排空任务池成功"})
+}
+
+// @Summary 调整worker数量
+// @Description 运行时调整并发worker数量
+// @Security ApiKeyAuth
+// @Tags 任务池
+// @Accept json
+// @Produce json
+// @Param resize body model.PoolResizeForm true "worker数量"
+// @Success 200 {object} model.ApiRespone "调整worker数量成功"
+// @Failure 500 {object} model.ApiRespone "调整worker数量失败"
+// @Router /pool/resize [put]
+func ResizePool(ctx *gin.Context) {
+	var resizeForm model.PoolResizeForm
+	if err := ctx.ShouldBindJSON(&resizeForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := core.Tp.Resize(resizeForm.Workers); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "调整worker数量成功"})
+}
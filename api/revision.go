@@ -0,0 +1,111 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 修订版本列表
+// @Description 任务流水线的修订版本列表, 最新的在前
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Produce json
+// @Param name path string true "任务名"
+// @Success 200 {object} model.ApiRespone "获取修订版本成功"
+// @Failure 500 {object} model.ApiRespone "获取修订版本失败"
+// @Router /task/revisions/{name} [get]
+func RevisionLists(ctx *gin.Context) {
+	revisions, err := service.RevisionLists(TaskNameParam(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取修订版本成功", Data: revisions})
+}
+
+// @Summary 修订版本详情
+// @Description 查看任务某个修订版本的流水线
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Produce json
+// @Param name path string true "任务名"
+// @Param revision path int true "版本号"
+// @Success 200 {object} model.ApiRespone "获取修订版本成功"
+// @Failure 500 {object} model.ApiRespone "修订版本不存在"
+// @Router /task/revision/{revision}/{name} [get]
+func RevisionDetail(ctx *gin.Context) {
+	revision, err := strconv.Atoi(ctx.Param("revision"))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	rev, err := service.RevisionDetail(TaskNameParam(ctx), revision)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取修订版本成功", Data: rev})
+}
+
+// @Summary 修订版本对比
+// @Description 两个修订版本之间的统一格式diff, to为空时与当前版本比较
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Produce json
+// @Param name path string true "任务名"
+// @Param from query int true "旧版本号"
+// @Param to query int false "新版本号"
+// @Success 200 {object} model.ApiRespone "对比修订版本成功"
+// @Failure 500 {object} model.ApiRespone "修订版本不存在"
+// @Router /task/diff/{name} [get]
+func RevisionDiff(ctx *gin.Context) {
+	from, err := strconv.Atoi(ctx.Query("from"))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	to, _ := strconv.Atoi(ctx.DefaultQuery("to", "0"))
+	diff, err := service.DiffRevisions(TaskNameParam(ctx), from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "对比修订版本成功", Data: diff})
+}
+
+// @Summary 恢复修订版本
+// @Description 将任务流水线恢复为指定版本, 恢复操作记录为新版本
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Produce json
+// @Param name path string true "任务名"
+// @Param revision path int true "版本号"
+// @Success 200 {object} model.ApiRespone "恢复修订版本成功"
+// @Failure 500 {object} model.ApiRespone "恢复修订版本失败"
+// @Router /task/restore/{revision}/{name} [post]
+func RestoreRevision(ctx *gin.Context) {
+	name := TaskNameParam(ctx)
+	revision, err := strconv.Atoi(ctx.Param("revision"))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "task:"+name)
+	before, after, err := service.RestoreRevision(name, revision, ctx.GetString(core.UsernameKey), core.Checker(ctx, core.PermRun))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditDetail(ctx, core.PipelineDiff(name, before, after))
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "恢复修订版本成功"})
+}
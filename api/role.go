@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 创建角色绑定
+// @Description 为用户在全局或任务范围内绑定角色
+// @Security ApiKeyAuth
+// @Tags 角色
+// @Accept json
+// @Produce json
+// @Param binding body model.RoleBindingForm true "角色绑定请求参数"
+// @Success 200 {object} model.ApiRespone "创建角色绑定成功"
+// @Failure 500 {object} model.ApiRespone "创建角色绑定失败"
+// @Router /role/add [post]
+func CreateRoleBinding(ctx *gin.Context) {
+	var bindingForm model.RoleBindingForm
+	if err := ctx.ShouldBindJSON(&bindingForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "user:"+bindingForm.UserName)
+	if err := service.CreateRoleBinding(bindingForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "创建角色绑定成功"})
+}
+
+// @Summary 删除角色绑定
+// @Description 角色绑定删除接口
+// @Security ApiKeyAuth
+// @Tags 角色
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} model.ApiRespone "删除角色绑定成功"
+// @Failure 500 {object} model.ApiRespone "删除角色绑定失败"
+// @Router /role/del/{id} [delete]
+func DeleteRoleBinding(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := service.DeleteRoleBinding(id); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "删除角色绑定成功"})
+}
+
+// @Summary 角色绑定列表
+// @Description 角色绑定列表接口
+// @Security ApiKeyAuth
+// @Tags 角色
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "获取角色绑定列表成功"
+// @Failure 500 {object} model.ApiRespone "获取角色绑定列表失败"
+// @Router /role/list [get]
+func RoleBindingLists(ctx *gin.Context) {
+	bindings, err := service.RoleBindingLists()
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取角色绑定列表成功", Data: bindings})
+}
+
+// 以下函数从请求中解析权限检查的任务范围
+
+// 路径参数中的任务名, 以通配参数匹配, 支持team-a/services/api形式的路径
+func TaskNameParam(ctx *gin.Context) string {
+	return strings.TrimPrefix(ctx.Param("name"), "/")
+}
+
+// 路径参数id对应的任务名
+func TaskIdParam(ctx *gin.Context) string {
+	return service.TaskNameById(ctx.Param("id"))
+}
+
+// 路径参数id对应审批的任务名
+func ApprovalIdParam(ctx *gin.Context) string {
+	return service.ApprovalTaskName(ctx.Param("id"))
+}
+
+// 路径参数id对应排队任务的任务名
+func QueuedIdParam(ctx *gin.Context) string {
+	id, _ := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	for _, queued := range core.Tp.Snapshot().Queued {
+		if queued.Id == id {
+			return queued.Name
+		}
+	}
+	return ""
+}
+
+// 读取JSON请求体到v, 并还原请求体供处理函数绑定
+func peekBody(ctx *gin.Context, v any) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return
+	}
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	json.Unmarshal(body, v)
+}
+
+// 请求体中的任务名
+func TaskNameBody(ctx *gin.Context) string {
+	var task struct {
+		Name string `json:"name"`
+	}
+	peekBody(ctx, &task)
+	return task.Name
+}
+
+func folderBody(ctx *gin.Context) string {
+	var folder struct {
+		Path string `json:"path"`
+	}
+	peekBody(ctx, &folder)
+	return folder.Path
+}
+
+// 请求体中目录的上级目录, 创建目录需要上级目录的权限
+func FolderParentBody(ctx *gin.Context) string {
+	return core.FolderScope(core.ParentPath(folderBody(ctx)))
+}
+
+// 请求体中的目录
+func FolderPathBody(ctx *gin.Context) string {
+	return core.FolderScope(folderBody(ctx))
+}
+
+// 路径参数中的目录
+func FolderPathParam(ctx *gin.Context) string {
+	return core.FolderScope(strings.TrimPrefix(ctx.Param("path"), "/"))
+}
+
+func moveBody(ctx *gin.Context) model.MoveForm {
+	var form model.MoveForm
+	peekBody(ctx, &form)
+	return form
+}
+
+// 移动任务的原任务名
+func MoveFromBody(ctx *gin.Context) string {
+	return moveBody(ctx).From
+}
+
+// 移动任务的新任务名
+func MoveToBody(ctx *gin.Context) string {
+	return moveBody(ctx).To
+}
+
+// 移动目录的原目录
+func MoveFromFolder(ctx *gin.Context) string {
+	return core.FolderScope(moveBody(ctx).From)
+}
+
+// 移动目录的新目录
+func MoveToFolder(ctx *gin.Context) string {
+	return core.FolderScope(moveBody(ctx).To)
+}
@@ -1,10 +1,10 @@
 package api
 
 import (
-	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"gookins/core"
 	"gookins/model"
@@ -30,11 +30,17 @@ func CreateTask(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	if err := service.CreateTask(taskForm); err != nil {
+	core.SetAuditTarget(ctx, "task:"+taskForm.Name)
+	if err := service.CheckTriggerTargets(taskForm.PipeLine, core.Checker(ctx, core.PermRun)); err != nil {
+		ctx.JSON(http.StatusForbidden, model.ApiRespone{Code: http.StatusForbidden, Message: err.Error()})
+		return
+	}
+	if err := service.CreateTask(taskForm, ctx.GetString(core.UsernameKey)); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
+	core.SetAuditDetail(ctx, core.PipelineDiff(taskForm.Name, "", taskForm.PipeLine))
 	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "创建任务成功"})
 }
 
@@ -56,11 +62,15 @@ func DeleteTask(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
+	name := service.TaskNameById(idStr)
+	before := service.TaskPipeline(name)
+	core.SetAuditTarget(ctx, "task:"+name)
 	if err := service.DeleteTask(id); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
+	core.SetAuditDetail(ctx, core.PipelineDiff(name, before, ""))
 	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "删除任务成功"})
 }
 
@@ -79,12 +89,20 @@ func UpdateTask(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&taskForm); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	before := service.TaskPipeline(taskForm.Name)
+	core.SetAuditTarget(ctx, "task:"+taskForm.Name)
+	if err := service.CheckTriggerTargets(taskForm.PipeLine, core.Checker(ctx, core.PermRun)); err != nil {
+		ctx.JSON(http.StatusForbidden, model.ApiRespone{Code: http.StatusForbidden, Message: err.Error()})
+		return
 	}
-	if err := service.UpdateTask(taskForm); err != nil {
+	if err := service.UpdateTask(taskForm, ctx.GetString(core.UsernameKey)); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
+	core.SetAuditDetail(ctx, core.PipelineDiff(taskForm.Name, before, taskForm.PipeLine))
 	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "更新任务成功"})
 }
 
@@ -94,6 +112,8 @@ func UpdateTask(ctx *gin.Context) {
 // @Tags 任务
 // @Accept json
 // @Produce json
+// @Param folder query string false "只列出该目录下的任务"
+// @Param recursive query bool false "包含子目录中的任务"
 // @Success 200 {object} model.ApiRespone "获取任务成功"
 // @Failure 500 {object} model.ApiRespone "获取任务失败"
 // @Router /task/list [get]
@@ -104,7 +124,32 @@ func TaskLists(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取任务列表成功", Data: tasks})
+	folder, filter := ctx.GetQuery("folder")
+	recursive := ctx.Query("recursive") == "true"
+	// 只返回当前用户有查看权限的任务, 没有编辑权限时只返回掩码后的流水线
+	canView, canEdit := core.Checker(ctx, core.PermView), core.Checker(ctx, core.PermEdit)
+	visible := make([]model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if filter && !inFolder(task.Name, folder, recursive) {
+			continue
+		}
+		if !canView(task.Name) {
+			continue
+		}
+		if !canEdit(task.Name) {
+			task.PipeLine = ""
+		}
+		visible = append(visible, task)
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取任务列表成功", Data: visible})
+}
+
+// 任务是否位于目录下, folder为空表示顶层
+func inFolder(name, folder string, recursive bool) bool {
+	if recursive {
+		return folder == "" || strings.HasPrefix(name, folder+"/")
+	}
+	return core.ParentPath(name) == folder
 }
 
 // @Summary 运行任务
@@ -113,28 +158,31 @@ func TaskLists(ctx *gin.Context) {
 // @Tags 任务
 // @Accept json
 // @Produce json
-// @Param task body model.TaskForm true "添加务请求参数"
+// @Param task body model.RunForm true "运行任务请求参数"
 // @Success 200 {object} model.ApiRespone "添加任务到任务池成功"
 // @Failure 500 {object} model.ApiRespone "添加任务到任务池失败"
 // @Router /task/run [post]
 func RunTask(ctx *gin.Context) {
-	var taskForm model.TaskForm
-	if err := ctx.ShouldBindJSON(&taskForm); err != nil {
+	var runForm model.RunForm
+	if err := ctx.ShouldBindJSON(&runForm); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	job := &core.TaskJob{
-		Id:       taskForm.Id,
-		Name:     taskForm.Name,
-		PipeLine: taskForm.PipeLine,
+	core.SetAuditTarget(ctx, "task:"+runForm.Name)
+	job, err := service.RunJob(runForm.Name, runForm.Params, runForm.Branch, runForm.ChangeId)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
 	}
-	if err := core.Tp.AddTask(job); err != nil {
+	buildId, err := core.Tp.AddTask(job)
+	if err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "任务加入任务池成功"})
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "任务加入任务池成功", Data: buildId})
 }
 
 // @Summary hook运行任务
@@ -162,7 +210,7 @@ func TaskHook(ctx *gin.Context) {
 // @Failure 500 {object} model.ApiRespone "取消任务失败"
 // @Router /task/cancel/{name} [post]
 func CancelTask(ctx *gin.Context) {
-	name := ctx.Param("name")
+	name := TaskNameParam(ctx)
 	if !core.Tp.CancelTask(name) {
 		slog.Error("取消任务失败")
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: "取消任务失败"})
@@ -182,14 +230,13 @@ func CancelTask(ctx *gin.Context) {
 // @Failure 401 {object} model.ApiRespone "获取任务状态失败"
 // @Router /task/state/{name} [get]
 func TaskStatus(ctx *gin.Context) {
-	name := ctx.Param("name")
+	name := TaskNameParam(ctx)
 	status, exists := core.Tp.GetTaskStatus(name)
 	if !exists {
 		slog.Error("任务不存在")
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: "任务不存在"})
 		return
 	}
-	fmt.Println("status: ", status)
 	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取任务状态成功", Data: status})
 }
 
@@ -200,11 +247,11 @@ func TaskStatus(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param name path string true "name"
-// @Success 200 {object} model.ApiRespone "取消任务成功"
-// @Failure 500 {object} model.ApiRespone "取消任务失败"
-// @Router /task/cancel/{name} [post]
+// @Success 200 {object} model.ApiRespone "禁用任务成功"
+// @Failure 500 {object} model.ApiRespone "禁用任务失败"
+// @Router /task/disable/{name} [post]
 func TaskDisabled(ctx *gin.Context) {
-	name := ctx.Param("name")
+	name := TaskNameParam(ctx)
 	tasks, err := service.TaskLists()
 	if err != nil {
 		slog.Error(err.Error())
@@ -220,3 +267,23 @@ func TaskDisabled(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 400, Message: "未找到任务"})
 }
+
+// @Summary 扫描多分支任务
+// @Description 扫描仓库分支和合并请求, 同步子任务
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Accept json
+// @Produce json
+// @Param name path string true "name"
+// @Success 200 {object} model.ApiRespone "扫描多分支任务成功"
+// @Failure 500 {object} model.ApiRespone "扫描多分支任务失败"
+// @Router /task/scan/{name} [post]
+func ScanTask(ctx *gin.Context) {
+	name := TaskNameParam(ctx)
+	if err := service.ScanMultibranch(ctx.Request.Context(), name); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "扫描多分支任务成功"})
+}
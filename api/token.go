@@ -0,0 +1,84 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 创建API令牌
+// @Description 为当前用户创建长期API令牌, 明文只返回一次
+// @Security ApiKeyAuth
+// @Tags API令牌
+// @Accept json
+// @Produce json
+// @Param token body model.ApiTokenForm true "创建API令牌请求参数"
+// @Success 200 {object} model.ApiRespone{data=model.ApiTokenRespon} "创建API令牌成功"
+// @Failure 500 {object} model.ApiRespone "创建API令牌失败"
+// @Router /token/add [post]
+func CreateApiToken(ctx *gin.Context) {
+	var tokenForm model.ApiTokenForm
+	if err := ctx.ShouldBindJSON(&tokenForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	token, err := service.CreateApiToken(ctx.GetString(core.UsernameKey), tokenForm)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "创建API令牌成功", Data: token})
+}
+
+// @Summary 吊销API令牌
+// @Description 吊销当前用户的API令牌
+// @Security ApiKeyAuth
+// @Tags API令牌
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} model.ApiRespone "吊销API令牌成功"
+// @Failure 500 {object} model.ApiRespone "吊销API令牌失败"
+// @Router /token/del/{id} [delete]
+func DeleteApiToken(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := service.DeleteApiToken(ctx.GetString(core.UsernameKey), id); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "吊销API令牌成功"})
+}
+
+// @Summary API令牌列表
+// @Description 当前用户的API令牌及最后使用时间
+// @Security ApiKeyAuth
+// @Tags API令牌
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "获取API令牌列表成功"
+// @Failure 500 {object} model.ApiRespone "获取API令牌列表失败"
+// @Router /token/list [get]
+func ApiTokenLists(ctx *gin.Context) {
+	tokens, err := service.ApiTokenLists(ctx.GetString(core.UsernameKey))
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "获取API令牌列表成功", Data: tokens})
+}
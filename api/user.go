@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -20,6 +21,7 @@ import (
 // @Param login body model.LoginForm true "登录请求参数"
 // @Success 200 {object} model.LoginRespon "成功返回Token"
 // @Failure 500 {object} model.ApiRespone "无效的凭据"
+// @Failure 429 {object} model.ApiRespone "登录尝试过于频繁"
 // @Router /login [post]
 func UserSign(ctx *gin.Context) {
 	var userForm model.LoginForm
@@ -28,18 +30,78 @@ func UserSign(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	if err := service.UserSign(userForm); err != nil {
+	core.SetAuditTarget(ctx, "user:"+userForm.Name)
+	ip := ctx.ClientIP()
+	if wait, err := core.CheckLogin(ip, userForm.Name); err != nil {
+		seconds := int(wait.Seconds())
+		ctx.Header("Retry-After", strconv.Itoa(seconds))
+		ctx.JSON(http.StatusTooManyRequests, model.ApiRespone{Code: 429, Message: fmt.Sprintf("%s, 请%d秒后重试", err, seconds)})
+		return
+	}
+	username, err := service.UserSign(userForm)
+	if err != nil {
+		// 失败已在CheckLogin中预先计数
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 404, Message: err.Error()})
 		return
 	}
-	token, err := core.CreateToken(userForm.Name)
+	// 会话、账号计数和审计都使用认证后端确认的用户名
+	core.SetAuditTarget(ctx, "user:"+username)
+	core.LoginSucceeded(ip, userForm.Name, username)
+	token, refresh, err := core.CreateSession(username)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.LoginRespon{Code: 200, Message: "登陆成功", Token: token, RefreshToken: refresh})
+}
+
+// @Summary 刷新token
+// @Description 使用刷新令牌换取新的访问token, 刷新令牌同时轮换
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param refresh body model.RefreshForm true "刷新令牌"
+// @Success 200 {object} model.LoginRespon "成功返回Token"
+// @Failure 401 {object} model.ApiRespone "刷新令牌无效"
+// @Router /refresh [post]
+func RefreshToken(ctx *gin.Context) {
+	var form model.RefreshForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	token, refresh, err := core.RefreshSession(form.RefreshToken)
 	if err != nil {
 		slog.Error(err.Error())
+		ctx.JSON(http.StatusUnauthorized, model.ApiRespone{Code: 401, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.LoginRespon{Code: 200, Message: "刷新成功", Token: token, RefreshToken: refresh})
+}
+
+// @Summary 用户登出
+// @Description 吊销当前登录会话, 访问token和刷新令牌立即失效
+// @Security ApiKeyAuth
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.ApiRespone "登出成功"
+// @Failure 500 {object} model.ApiRespone "登出失败"
+// @Router /logout [post]
+func UserLogout(ctx *gin.Context) {
+	sid := ctx.GetUint(core.SessionKey)
+	if sid == 0 {
+		ctx.JSON(http.StatusBadRequest, model.ApiRespone{Code: 400, Message: "API token无需登出"})
+		return
+	}
+	if err := core.RevokeSession(sid); err != nil {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, model.LoginRespon{Code: 200, Message: "登陆成功", Token: token})
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "登出成功"})
 }
 
 // @Summary 创建用户
@@ -59,6 +121,7 @@ func CreateUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
 		return
 	}
+	core.SetAuditTarget(ctx, "user:"+userForm.Name)
 	if err := service.CreateUser(userForm); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
@@ -93,6 +156,46 @@ func DeleteUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "删除用户成功"})
 }
 
+// @Summary 禁用用户
+// @Description 禁用或启用用户, 禁用后其会话立即失效
+// @Security ApiKeyAuth
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param id path string true "用户ID"
+// @Success 200 {object} model.ApiRespone "更新用户状态成功"
+// @Failure 500 {object} model.ApiRespone "更新用户状态失败"
+// @Router /user/disable/{id} [post]
+func UserDisabled(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	disabled, err := service.UserDisable(id)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "更新用户状态成功", Data: gin.H{"disabled": disabled}})
+}
+
+// @Summary 解锁用户
+// @Description 清除账号的登录失败计数, 解除锁定
+// @Security ApiKeyAuth
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param name path string true "用户名"
+// @Success 200 {object} model.ApiRespone "解锁用户成功"
+// @Router /user/unlock/{name} [post]
+func UnlockUser(ctx *gin.Context) {
+	locked := core.UnlockLogin(ctx.GetString(core.UsernameKey), ctx.Param("name"), ctx.ClientIP())
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "解锁用户成功", Data: gin.H{"locked": locked}})
+}
+
 // @Summary 更新用户
 // @Description 用户更新接口
 // @Security ApiKeyAuth
@@ -105,6 +208,12 @@ func DeleteUser(ctx *gin.Context) {
 // @Router /user/upt/{id} [put]
 func UpdateUser(ctx *gin.Context) {
 	var userForm model.UserForm
+	if err := ctx.ShouldBindJSON(&userForm); err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	core.SetAuditTarget(ctx, "user:"+userForm.Name)
 	if err := service.UpdateUser(userForm); err != nil {
 		slog.Error(err.Error())
 		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
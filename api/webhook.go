@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 生成webhook地址
+// @Description 为任务生成新的通用webhook令牌, 旧地址随之失效
+// @Security ApiKeyAuth
+// @Tags 任务
+// @Accept json
+// @Produce json
+// @Param name path string true "name"
+// @Success 200 {object} model.ApiRespone "生成webhook地址成功"
+// @Failure 500 {object} model.ApiRespone "生成webhook地址失败"
+// @Router /task/webhook/{name} [post]
+func ResetWebhook(ctx *gin.Context) {
+	name := TaskNameParam(ctx)
+	token, err := service.ResetWebhookToken(name)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	url := fmt.Sprintf("%s/webhook/%s", strings.TrimSuffix(core.Config.ExternalUrl, "/"), token)
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "生成webhook地址成功", Data: url})
+}
+
+// @Summary 通用webhook触发
+// @Description 由外部系统调用, 按任务配置提取参数并触发构建
+// @Tags 任务
+// @Accept json
+// @Produce json
+// @Param token path string true "webhook令牌"
+// @Success 200 {object} model.ApiRespone "触发构建成功, data为构建ID"
+// @Failure 404 {object} model.ApiRespone "webhook不存在"
+// @Router /webhook/{token} [post]
+func GenericWebhook(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	buildId, triggered, err := service.TriggerWebhook(ctx.Param("token"), ctx.Request.Header, body)
+	if err == service.ErrWebhookNotFound {
+		ctx.JSON(http.StatusNotFound, model.ApiRespone{Code: 404, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		ctx.JSON(http.StatusInternalServerError, model.ApiRespone{Code: 500, Message: err.Error()})
+		return
+	}
+	if !triggered {
+		ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "请求未通过过滤规则, 未触发构建"})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.ApiRespone{Code: 200, Message: "触发构建成功", Data: buildId})
+}
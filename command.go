@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gookins/core"
+	"gookins/model"
+	"gookins/service"
+)
+
+const commandUsage = `用法: gookins [命令] [参数]
+
+不带命令时启动服务. 命令:
+  export [-name 任务] [-folder 目录] [-format yaml|tar] [-o 文件]   导出任务
+  import [-dry-run] [-conflict skip|overwrite|rename] 文件          导入任务
+  migrate [up|down [-steps n]|status]                                 执行、回滚或查看数据库迁移
+`
+
+// 命令行子命令, 不启动服务直接操作数据库, 返回进程退出码
+func runCommand(args []string) int {
+	var err error
+	switch args[0] {
+	case "export", "import":
+		// 与启动服务时一样先迁移数据库, migrate子命令则自己执行迁移
+		if err = core.PrepareDb(); err != nil {
+			break
+		}
+		if args[0] == "export" {
+			err = exportCommand(args[1:])
+		} else {
+			err = importCommand(args[1:])
+		}
+	case "migrate":
+		err = migrateCommand(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(commandUsage)
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	var query model.ExportQuery
+	flags.StringVar(&query.Name, "name", "", "导出单个任务")
+	flags.StringVar(&query.Folder, "folder", "", "导出目录下的所有任务")
+	flags.StringVar(&query.Format, "format", model.BundleFormatYaml, "yaml或tar(tar.gz)")
+	output := flags.String("o", "", "输出文件, 默认为标准输出")
+	flags.Parse(args)
+	if query.Format != model.BundleFormatYaml && query.Format != model.BundleFormatTar {
+		return fmt.Errorf("无效的格式: %s", query.Format)
+	}
+	bundle, err := service.ExportBundle(query, nil)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := service.WriteBundle(w, bundle, query.Format); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "导出%d个目录, %d个任务\n", len(bundle.Folders), len(bundle.Tasks))
+	return nil
+}
+
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	var query model.ImportQuery
+	flags.BoolVar(&query.DryRun, "dry-run", false, "只检查并输出报告, 不写入")
+	flags.StringVar(&query.Conflict, "conflict", model.ConflictSkip, "同名任务的处理方式: skip、overwrite或rename")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("需要指定一个导入文件")
+	}
+	switch query.Conflict {
+	case model.ConflictSkip, model.ConflictOverwrite, model.ConflictRename:
+	default:
+		return fmt.Errorf("无效的冲突处理方式: %s", query.Conflict)
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	bundle, err := service.ReadBundle(file)
+	if err != nil {
+		return err
+	}
+	report, err := service.ImportBundle(bundle, query, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, item := range report.Items {
+		line := fmt.Sprintf("%-9s %-6s %s", item.Action, item.Kind, item.Name)
+		if item.Target != "" {
+			line += " -> " + item.Target
+		}
+		if item.Message != "" {
+			line += " (" + item.Message + ")"
+		}
+		fmt.Println(line)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Println("警告: " + warning)
+	}
+	fmt.Println(service.ImportSummary(report))
+	if report.Failed > 0 {
+		return fmt.Errorf("%d项导入失败", report.Failed)
+	}
+	return nil
+}
+
+func migrateCommand(args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	switch action {
+	case "up":
+		return core.Migrate()
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := flags.Int("steps", 1, "回滚的迁移数量")
+		flags.Parse(args)
+		if *steps < 1 {
+			return fmt.Errorf("无效的回滚数量: %d", *steps)
+		}
+		return core.MigrateDown(*steps)
+	case "status":
+		statuses, err := core.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "未执行"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-28s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的migrate操作: %s", action)
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gookins/model"
+)
+
+var (
+	ErrApprovalNotFound = errors.New("审批不存在或已处理")
+	ErrApprovalDenied   = errors.New("当前用户不是该审批的审批人")
+)
+
+// 审批超时检查间隔
+const approvalCheckInterval = 30 * time.Second
+
+// 创建审批记录并通知审批人, 构建随后进入waiting状态并释放worker
+func (tp *TaskPool) requestApproval(id uint64, task *TaskJob, index int, stepName string, step *approvalStep) error {
+	approval := model.Approval{
+		BuildId:   id,
+		TaskName:  task.Name,
+		Step:      index,
+		StepName:  stepName,
+		Message:   step.Message,
+		Approvers: strings.Join(step.Approvers, ","),
+		State:     model.ApprovalPending,
+	}
+	if step.Timeout > 0 {
+		deadline := time.Now().Add(time.Duration(step.Timeout) * time.Minute)
+		approval.Deadline = &deadline
+	}
+	if result := Db.Create(&approval); result.Error != nil {
+		return result.Error
+	}
+	slog.Info(fmt.Sprintf("Task %s build %d waiting for approval %d: %s, approvers: %s", task.Name, id, approval.ID, step.Message, approval.Approvers))
+	notify(map[string]any{
+		"event":       "approval",
+		"approval_id": approval.ID,
+		"build_id":    id,
+		"task":        task.Name,
+		"step":        stepName,
+		"message":     step.Message,
+		"approvers":   step.Approvers,
+		"url":         fmt.Sprintf("%s/task?build=%d", strings.TrimSuffix(Config.ExternalUrl, "/"), id),
+	})
+	return nil
+}
+
+// 将通知POST到配置的notify_url
+func notify(payload map[string]any) {
+	if Config.NotifyUrl == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := forgeRequest(ctx, http.MethodPost, "", "", Config.NotifyUrl, payload, nil); err != nil {
+			slog.Error(fmt.Sprintf("notify: %v", err))
+		}
+	}()
+}
+
+// 审批或拒绝, 通过后构建从审批步骤的下一步继续排队执行
+func (tp *TaskPool) DecideApproval(id uint64, username string, approve bool) error {
+	var approval model.Approval
+	result := Db.Where("id = ? AND state = ?", id, model.ApprovalPending).Limit(1).Find(&approval)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrApprovalNotFound
+	}
+	if approval.Approvers != "" && !ContainsTaskName(approval.Approvers, username) {
+		return ErrApprovalDenied
+	}
+	state := model.ApprovalRejected
+	if approve {
+		state = model.ApprovalApproved
+		// 与新构建一样经过排空和容量检查, 通过后才记录审批结果
+		if err := tp.admit(&TaskJob{Name: approval.TaskName}, true); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	// 以state作为条件更新, 避免并发的重复审批
+	result = Db.Model(&approval).Where("state = ?", model.ApprovalPending).Updates(map[string]any{"state": state, "decided_by": username, "decided_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		if approve {
+			tp.release()
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return ErrApprovalNotFound
+	}
+	if result := Db.Model(&model.Build{}).Where("id = ?", approval.BuildId).Update("approver", username); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+	slog.Info(fmt.Sprintf("Approval %d for build %d %s by %s", approval.ID, approval.BuildId, state, username))
+	return tp.finishApproval(&approval, approve)
+}
+
+// 审批结束后恢复或终止构建. 通过时调用方已经用admit预留了队列位置
+func (tp *TaskPool) finishApproval(approval *model.Approval, approve bool) error {
+	var build model.Build
+	result := Db.Where("id = ?", approval.BuildId).Limit(1).Find(&build)
+	if result.Error != nil {
+		if approve {
+			tp.release()
+		}
+		return result.Error
+	}
+	task := jobFromBuild(&build)
+	if !approve {
+		tp.setState(approval.BuildId, task, TaskRejected)
+		RemoveBuildWorkspace(approval.BuildId)
+		return nil
+	}
+	// 保存恢复位置, 重启后重新排队的构建也从审批的下一步继续
+	task.ResumeStep = approval.Step + 1
+	if result := Db.Model(&model.Build{}).Where("id = ?", approval.BuildId).Update("resume_step", task.ResumeStep); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+	tp.setState(approval.BuildId, task, TaskPending)
+	return tp.enqueueReserved(approval.BuildId, task)
+}
+
+// 定期将超时的审批标记为timeout并终止对应构建
+func (tp *TaskPool) expireApprovals() {
+	ticker := time.NewTicker(approvalCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tp.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var approvals []model.Approval
+		result := Db.Where("state = ? AND deadline IS NOT NULL AND deadline < ?", model.ApprovalPending, time.Now()).Find(&approvals)
+		if result.Error != nil {
+			slog.Error(result.Error.Error())
+			continue
+		}
+		for _, approval := range approvals {
+			result := Db.Model(&approval).Where("state = ?", model.ApprovalPending).Updates(map[string]any{"state": model.ApprovalTimeout, "decided_at": time.Now()})
+			if result.Error != nil || result.RowsAffected == 0 {
+				continue
+			}
+			slog.Info(fmt.Sprintf("Approval %d for build %d timed out", approval.ID, approval.BuildId))
+			if err := tp.finishApproval(&approval, false); err != nil {
+				slog.Error(err.Error())
+			}
+		}
+	}
+}
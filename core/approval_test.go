@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"gookins/model"
+)
+
+func createWaitingBuild(t *testing.T) (model.Build, model.Approval) {
+	t.Helper()
+	build := model.Build{TaskName: "deploy", PipeLine: "steps:\n  - name: ok\n    approval: {}\n  - name: run\n    command: \"true\"\n", State: TaskWaiting}
+	if err := Db.Create(&build).Error; err != nil {
+		t.Fatal(err)
+	}
+	approval := model.Approval{BuildId: uint64(build.ID), TaskName: "deploy", Step: 0, State: model.ApprovalPending}
+	if err := Db.Create(&approval).Error; err != nil {
+		t.Fatal(err)
+	}
+	return build, approval
+}
+
+func TestApproveResumesThroughAdmission(t *testing.T) {
+	tp := newTestPool(t, 10, StrategyBlock)
+	build, approval := createWaitingBuild(t)
+	if err := tp.DecideApproval(uint64(approval.ID), "alice", true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tp.queue) != 1 || tp.queue[0].job.ResumeStep != 1 {
+		t.Fatalf("队列为%+v", tp.queue)
+	}
+	var saved model.Build
+	Db.Where("id = ?", build.ID).Find(&saved)
+	if saved.State != TaskPending || saved.ResumeStep != 1 {
+		t.Fatalf("构建状态为%s, 恢复步骤为%d", saved.State, saved.ResumeStep)
+	}
+	// 重启后由构建记录恢复的任务仍从审批的下一步开始
+	if job := jobFromBuild(&saved); job.ResumeStep != 1 {
+		t.Fatalf("恢复步骤为%d", job.ResumeStep)
+	}
+}
+
+// 排空时不能通过审批把构建放回队列, 审批保持待处理
+func TestApproveRejectedWhenDraining(t *testing.T) {
+	tp := newTestPool(t, 10, StrategyBlock)
+	_, approval := createWaitingBuild(t)
+	tp.draining = true
+	if err := tp.DecideApproval(uint64(approval.ID), "alice", true); !errors.Is(err, ErrTaskPoolDraining) {
+		t.Fatalf("err = %v", err)
+	}
+	var saved model.Approval
+	Db.Where("id = ?", approval.ID).Find(&saved)
+	if saved.State != model.ApprovalPending || len(tp.queue) != 0 || tp.reserved != 0 {
+		t.Fatalf("审批状态为%s, 排队数为%d", saved.State, len(tp.queue))
+	}
+}
+
+func TestFinishedStates(t *testing.T) {
+	for _, state := range []string{TaskFailure, TaskCancelled, TaskCompleted, TaskInterrupted, TaskRejected} {
+		if !finishedState(state) {
+			t.Errorf("%s应为结束状态", state)
+		}
+	}
+	for _, state := range []string{TaskPending, TaskRunning, TaskWaiting} {
+		if finishedState(state) {
+			t.Errorf("%s不是结束状态", state)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gookins/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 系统自动触发的审计事件的执行者
+	AuditSystem = "system"
+	// 未登录请求的执行者
+	AuditAnonymous = "anonymous"
+
+	auditTargetKey = "audit_target"
+	auditDetailKey = "audit_detail"
+)
+
+// 记录审计事件, 写入失败只记录日志, 不影响业务流程
+func Audit(actor, action, target, ip, detail string) {
+	auditLog(model.AuditLog{Actor: actor, Action: action, Target: target, Ip: ip, Detail: detail})
+}
+
+func auditLog(log model.AuditLog) {
+	if result := Db.Create(&log); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+}
+
+// 处理函数设置审计对象, 未设置时使用路径参数
+func SetAuditTarget(ctx *gin.Context, target string) {
+	ctx.Set(auditTargetKey, target)
+}
+
+// 处理函数设置审计详情, 如流水线变更的diff
+func SetAuditDetail(ctx *gin.Context, detail string) {
+	ctx.Set(auditDetailKey, detail)
+}
+
+// 流水线变更的diff, 已知凭据按任务掩码
+func PipelineDiff(taskName, before, after string) string {
+	return MaskSecrets(taskName, UnifiedDiff("a/"+taskName, "b/"+taskName, before, after))
+}
+
+// 审计中间件: 在处理完成后记录所有非只读请求, 动作为请求方法和路由模板
+func AuditMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			ctx.Next()
+			return
+		}
+		ctx.Next()
+		if ctx.FullPath() == "" {
+			return
+		}
+		actor := ctx.GetString(UsernameKey)
+		if actor == "" {
+			actor = AuditAnonymous
+		}
+		target := ctx.GetString(auditTargetKey)
+		if target == "" {
+			var params []string
+			for _, param := range ctx.Params {
+				// webhook令牌等秘密不写入审计
+				if param.Key == "token" {
+					continue
+				}
+				params = append(params, param.Key+"="+param.Value)
+			}
+			target = strings.Join(params, ",")
+		}
+		auditLog(model.AuditLog{
+			Actor:  actor,
+			Action: ctx.Request.Method + " " + ctx.FullPath(),
+			Target: target,
+			Ip:     ctx.ClientIP(),
+			Status: ctx.Writer.Status(),
+			Detail: ctx.GetString(auditDetailKey),
+		})
+	}
+}
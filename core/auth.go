@@ -0,0 +1,172 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"gookins/model"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	AuthLocal = "local"
+	AuthLdap  = "ldap"
+)
+
+var (
+	ErrAuthFailed = errors.New("用户名或密码错误")
+)
+
+// 认证成功后的身份, Roles为外部身份源组映射得到的角色
+type Identity struct {
+	Username string
+	Source   string
+	Roles    []GroupRole
+}
+
+// 用户名密码认证后端
+type Authenticator interface {
+	Authenticate(username, password string) (*Identity, error)
+}
+
+// IdP或目录中的用户组到gookins角色的映射
+type GroupRole struct {
+	Group string `yaml:"group"`
+	Role  string `yaml:"role"`
+	Scope string `yaml:"scope"`
+}
+
+var (
+	authenticators = map[string]Authenticator{
+		AuthLocal: localAuthenticator{},
+		AuthLdap:  ldapAuthenticator{},
+	}
+
+	// 用户不存在时也计算一次哈希, 使响应时间与密码错误一致
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func dummyVerify(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("gookins-dummy-password")
+	})
+	VerifyPassword(dummyHash, password)
+}
+
+// 按auth_chain配置依次尝试认证后端, 第一个成功的生效.
+// 本地后端放在最后可在目录服务不可用时让本地管理员登录
+func Authenticate(username, password string) (*Identity, error) {
+	chain := Config.AuthChain
+	if len(chain) == 0 {
+		chain = []string{AuthLocal}
+	}
+	for _, name := range chain {
+		auth, ok := authenticators[name]
+		if !ok {
+			slog.Error("未知的认证后端: " + name)
+			continue
+		}
+		identity, err := auth.Authenticate(username, password)
+		if err == nil {
+			return identity, nil
+		}
+		slog.Info(fmt.Sprintf("认证后端%s未通过用户%s: %s", name, username, err))
+	}
+	return nil, ErrAuthFailed
+}
+
+// 匹配到的组映射, 组名不区分大小写
+func MapGroupRoles(mappings []GroupRole, groups []string) []GroupRole {
+	var roles []GroupRole
+	for _, mapping := range mappings {
+		for _, group := range groups {
+			if strings.EqualFold(group, mapping.Group) {
+				roles = append(roles, mapping)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+type localAuthenticator struct{}
+
+func (localAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	var user model.User
+	result := Db.Where("name = ?", username).Limit(1).Find(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	// 外部身份源创建的用户没有本地密码
+	if result.RowsAffected == 0 || user.Password == "" {
+		dummyVerify(password)
+		return nil, ErrAuthFailed
+	}
+	if !VerifyPassword(user.Password, password) {
+		return nil, ErrAuthFailed
+	}
+	// 旧版HMAC哈希或哈希参数变化时透明地重新哈希
+	if NeedsRehash(user.Password) {
+		if hashed, err := HashPassword(password); err == nil {
+			if result := Db.Model(&user).Update("password", hashed); result.Error != nil {
+				slog.Error(result.Error.Error())
+			}
+		}
+	}
+	return &Identity{Username: user.Name, Source: model.UserSourceLocal}, nil
+}
+
+type ldapAuthenticator struct{}
+
+// 用服务账号(未配置时匿名)搜索用户DN, 再以用户的DN和密码绑定校验, 最后查询所属组
+func (ldapAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	cfg := Config.Ldap
+	if cfg.Url == "" {
+		return nil, ErrLdapDisabled
+	}
+	conn, err := ldapDial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if cfg.BindDn != "" {
+		if err := ldapBind(conn, cfg.BindDn, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("LDAP服务账号绑定失败: %w", err)
+		}
+	}
+	userDn, uid, err := ldapFindUser(conn, cfg, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := ldapBind(conn, userDn, password); err != nil {
+		return nil, err
+	}
+	var groups []string
+	if cfg.GroupBaseDn != "" && cfg.GroupFilter != "" {
+		// 以服务账号查询组, 避免依赖普通用户的读权限
+		if cfg.BindDn != "" {
+			if err := ldapBind(conn, cfg.BindDn, cfg.BindPassword); err != nil {
+				return nil, err
+			}
+		}
+		groupAttr := cfg.GroupAttr
+		if groupAttr == "" {
+			groupAttr = "cn"
+		}
+		filter := strings.NewReplacer("{username}", ldap.EscapeFilter(uid), "{dn}", ldap.EscapeFilter(userDn)).Replace(cfg.GroupFilter)
+		entries, err := ldapSearch(conn, cfg.GroupBaseDn, filter, []string{groupAttr}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			groups = append(groups, entry.GetAttributeValues(groupAttr)...)
+		}
+	}
+	// 以目录中的登录名为准, 输入的大小写或空白不同的登录名都映射到同一个用户
+	return &Identity{Username: uid, Source: model.UserSourceLdap, Roles: MapGroupRoles(cfg.GroupRoles, groups)}, nil
+}
@@ -7,33 +7,69 @@ import (
 )
 
 type config struct {
-	Address      string `yaml:"address"`
-	RunMode      string `yaml:"run_mode"`
-	JwtKey       string `yaml:"jwt_key"`
-	SaltKey      string `yaml:"salt_key"`
-	ExpiredTime  int64  `yaml:"expired_time"`
-	TaskPoolSize int    `yaml:"task_pool_size"`
-	WorkerCount  int    `yaml:"worker_count"`
-	Strategy     string `yaml:"strategy"`
-	DbHost       string `yaml:"db_host"`
-	DbPort       uint   `yaml:"db_port"`
-	DbUser       string `yaml:"db_user"`
-	DbPass       string `yaml:"db_pass"`
-	DbName       string `yaml:"db_name"`
-	CodeUser     string `yaml:"code_user"`
-	CodePass     string `yaml:"code_pass"`
-	WorkSpace    string `yaml:"workspace"`
+	Address              string     `yaml:"address"`
+	RunMode              string     `yaml:"run_mode"`
+	JwtKey               string     `yaml:"jwt_key"`
+	SaltKey              string     `yaml:"salt_key"`
+	ExpiredTime          int64      `yaml:"expired_time"`
+	RefreshExpiredTime   int64      `yaml:"refresh_expired_time"`
+	TaskPoolSize         int        `yaml:"task_pool_size"`
+	WorkerCount          int        `yaml:"worker_count"`
+	Strategy             string     `yaml:"strategy"`
+	ShutdownGrace        int64      `yaml:"shutdown_grace"`
+	RequeueInterrupted   bool       `yaml:"requeue_interrupted"`
+	DbDriver             string     `yaml:"db_driver"`
+	DbPath               string     `yaml:"db_path"`
+	DbTimezone           string     `yaml:"db_timezone"`
+	DbHost               string     `yaml:"db_host"`
+	DbPort               uint       `yaml:"db_port"`
+	DbUser               string     `yaml:"db_user"`
+	DbPass               string     `yaml:"db_pass"`
+	DbName               string     `yaml:"db_name"`
+	ManualMigrate        bool       `yaml:"manual_migrate"`
+	CodeUser             string     `yaml:"code_user"`
+	CodePass             string     `yaml:"code_pass"`
+	WorkSpace            string     `yaml:"workspace"`
+	ForgeToken           string     `yaml:"forge_token"`
+	ForgeUrl             string     `yaml:"forge_url"`
+	ExternalUrl          string     `yaml:"external_url"`
+	NotifyUrl            string     `yaml:"notify_url"`
+	MasterKey            string     `yaml:"master_key"`
+	HashTime             uint32     `yaml:"hash_time"`
+	HashMemory           uint32     `yaml:"hash_memory"`
+	HashThreads          uint8      `yaml:"hash_threads"`
+	PasswordMinLength    int        `yaml:"password_min_length"`
+	PasswordBreachedFile string     `yaml:"password_breached_file"`
+	ScanInterval         int64      `yaml:"scan_interval"`
+	LoginMaxFailures     int        `yaml:"login_max_failures"`
+	LoginIpMaxFailures   int        `yaml:"login_ip_max_failures"`
+	LoginLockout         int64      `yaml:"login_lockout"`
+	LoginBackoffBase     int64      `yaml:"login_backoff_base"`
+	TrustedProxies       []string   `yaml:"trusted_proxies"`
+	AuthChain            []string   `yaml:"auth_chain"`
+	Ldap                 ldapConfig `yaml:"ldap"`
+	Oidc                 oidcConfig `yaml:"oidc"`
 }
 
-func init() {
-	once.Do(func() {
-		data, err := os.ReadFile("config.yaml")
-		if err != nil {
-			panic(err)
-		}
-		err = yaml.Unmarshal(data, &Config)
-		if err != nil {
-			panic(err)
-		}
-	})
+// 读取配置文件, 路径由环境变量GOOKINS_CONFIG指定, 默认为config.yaml
+func LoadConfig() error {
+	path := os.Getenv("GOOKINS_CONFIG")
+	if path == "" {
+		path = "config.yaml"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &Config); err != nil {
+		return err
+	}
+	// 便于测试时不修改配置文件而改用内存数据库
+	if driver := os.Getenv("GOOKINS_DB_DRIVER"); driver != "" {
+		Config.DbDriver = driver
+	}
+	if path := os.Getenv("GOOKINS_DB_PATH"); path != "" {
+		Config.DbPath = path
+	}
+	return nil
 }
@@ -0,0 +1,28 @@
+// 测试辅助函数: 在内存SQLite数据库上运行依赖core.Db的测试
+package coretest
+
+import (
+	"testing"
+
+	"gookins/core"
+)
+
+// 打开一个新的内存数据库并执行全部迁移, 测试结束时关闭并恢复原来的连接
+func OpenDb(t testing.TB) {
+	t.Helper()
+	oldDb, oldDriver, oldPath := core.Db, core.Config.DbDriver, core.Config.DbPath
+	core.Config.DbDriver, core.Config.DbPath = core.DbSqlite, ":memory:"
+	if err := core.OpenDb(); err != nil {
+		t.Fatal(err)
+	}
+	db := core.Db
+	t.Cleanup(func() {
+		if sqlDb, err := db.DB(); err == nil {
+			sqlDb.Close()
+		}
+		core.Db, core.Config.DbDriver, core.Config.DbPath = oldDb, oldDriver, oldPath
+	})
+	if err := core.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMasterKey          = errors.New("未配置凭据加密主密钥")
+	ErrDecryptCredential  = errors.New("解密凭据失败")
+	ErrCredentialNotFound = errors.New("凭据不存在")
+)
+
+// 由主密钥派生AES-256密钥, 环境变量优先于配置文件
+func masterKey() ([]byte, error) {
+	key := os.Getenv("GOOKINS_MASTER_KEY")
+	if key == "" {
+		key = Config.MasterKey
+	}
+	if key == "" {
+		return nil, ErrMasterKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	return sum[:], nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 使用AES-GCM加密, 返回base64(nonce|密文)
+func EncryptSecret(plaintext string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(encoded string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", ErrDecryptCredential
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrDecryptCredential
+	}
+	return string(plaintext), nil
+}
+
+// 查找任务可用的凭据, 任务范围的凭据优先于全局凭据. 在事务中调用时db为事务的tx
+func lookupCredential(db *gorm.DB, taskName, credId string) (*model.Credential, error) {
+	var credentials []model.Credential
+	result := db.Where("cred_id = ? AND scope IN ?", credId, scopeChain(taskName)).Find(&credentials)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCredentialNotFound, credId)
+	}
+	// 范围最具体的优先: 任务本身, 然后由内到外的各级目录, 最后是全局
+	best := &credentials[0]
+	for i := range credentials {
+		if len(credentials[i].Scope) > len(best.Scope) {
+			best = &credentials[i]
+		}
+	}
+	return best, nil
+}
+
+// 任务是否能引用到该凭据, 在事务中调用时db为事务的tx
+func CredentialExists(db *gorm.DB, taskName, credId string) bool {
+	_, err := lookupCredential(db, taskName, credId)
+	return err == nil
+}
+
+// 注入到一个步骤中的凭据
+type injectedCredentials struct {
+	env []string
+	// 写入的临时文件或目录
+	files []string
+}
+
+// 删除写入的临时文件
+func (ic *injectedCredentials) cleanup() {
+	for _, file := range ic.files {
+		os.RemoveAll(file)
+	}
+}
+
+// 将凭据写入临时文件, 返回文件路径. 指定了文件名的file凭据写入单独的临时目录并使用该文件名,
+// 以便要求特定文件名的工具(如kubeconfig、.npmrc)直接使用
+func (ic *injectedCredentials) writeFile(fileName, content string) (string, error) {
+	var file *os.File
+	var err error
+	if name := filepath.Base(fileName); fileName != "" && name != "." && name != ".." && name != string(filepath.Separator) {
+		dir, err := os.MkdirTemp("", "gookins-cred-*")
+		if err != nil {
+			return "", err
+		}
+		ic.files = append(ic.files, dir)
+		file, err = os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		file, err = os.CreateTemp("", "gookins-cred-*")
+		if err != nil {
+			return "", err
+		}
+		ic.files = append(ic.files, file.Name())
+	}
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return file.Name(), err
+}
+
+// 解析步骤引用的凭据, 生成环境变量和临时文件
+func injectCredentials(taskName string, bindings []credentialBinding) (*injectedCredentials, error) {
+	ic := &injectedCredentials{}
+	for _, binding := range bindings {
+		credential, err := lookupCredential(Db, taskName, binding.Id)
+		if err != nil {
+			ic.cleanup()
+			return nil, err
+		}
+		secret, err := DecryptSecret(credential.Secret)
+		if err != nil {
+			ic.cleanup()
+			return nil, err
+		}
+		switch credential.Kind {
+		case model.CredentialSecret:
+			if binding.Env != "" {
+				ic.env = append(ic.env, binding.Env+"="+secret)
+			}
+		case model.CredentialUsernamePassword:
+			if binding.UsernameEnv != "" {
+				ic.env = append(ic.env, binding.UsernameEnv+"="+credential.Username)
+			}
+			if binding.PasswordEnv != "" {
+				ic.env = append(ic.env, binding.PasswordEnv+"="+secret)
+			}
+		case model.CredentialSshKey, model.CredentialFile:
+			if binding.FileEnv == "" {
+				continue
+			}
+			fileName := ""
+			if credential.Kind == model.CredentialFile {
+				fileName = credential.FileName
+			}
+			path, err := ic.writeFile(fileName, secret)
+			if err != nil {
+				ic.cleanup()
+				return nil, err
+			}
+			ic.env = append(ic.env, binding.FileEnv+"="+path)
+		}
+	}
+	return ic, nil
+}
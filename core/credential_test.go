@@ -0,0 +1,71 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gookins/model"
+)
+
+func createTestCredential(t *testing.T, credential model.Credential, secret string) {
+	t.Helper()
+	encrypted, err := EncryptSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.Secret = encrypted
+	if err := Db.Create(&credential).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInjectFileCredentialName(t *testing.T) {
+	openTestDb(t)
+	oldKey := Config.MasterKey
+	Config.MasterKey = "test-key"
+	t.Cleanup(func() { Config.MasterKey = oldKey })
+
+	createTestCredential(t, model.Credential{CredId: "kube", Kind: model.CredentialFile, FileName: "config"}, "kube-content")
+	createTestCredential(t, model.Credential{CredId: "evil", Kind: model.CredentialFile, FileName: "../../etc/passwd"}, "evil-content")
+	createTestCredential(t, model.Credential{CredId: "key", Kind: model.CredentialSshKey}, "key-content")
+
+	ic, err := injectCredentials("app", []credentialBinding{
+		{Id: "kube", FileEnv: "KUBECONFIG"},
+		{Id: "evil", FileEnv: "EVIL"},
+		{Id: "key", FileEnv: "SSH_KEY"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]string{}
+	for _, env := range ic.env {
+		name, value, _ := strings.Cut(env, "=")
+		paths[name] = value
+	}
+	if filepath.Base(paths["KUBECONFIG"]) != "config" {
+		t.Errorf("file凭据应使用指定的文件名, 实际为%s", paths["KUBECONFIG"])
+	}
+	if filepath.Base(paths["EVIL"]) != "passwd" || !strings.HasPrefix(paths["EVIL"], os.TempDir()) {
+		t.Errorf("文件名应只取最后一级, 实际为%s", paths["EVIL"])
+	}
+	for name, want := range map[string]string{"KUBECONFIG": "kube-content", "EVIL": "evil-content", "SSH_KEY": "key-content"} {
+		content, err := os.ReadFile(paths[name])
+		if err != nil || string(content) != want {
+			t.Errorf("%s内容应为%s, 实际为%q, %v", name, want, content, err)
+		}
+	}
+
+	ic.cleanup()
+	for name, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("清理后%s仍然存在", name)
+		}
+		if name == "KUBECONFIG" {
+			if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+				t.Error("清理后临时目录仍然存在")
+			}
+		}
+	}
+}
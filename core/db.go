@@ -2,33 +2,90 @@ package core
 
 import (
 	"fmt"
-	"gookins/model"
-	"log/slog"
+	"strings"
+	"sync/atomic"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func init() {
-	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=disable TimeZone=Asia/Shanghai",
-		Config.DbHost, Config.DbUser, Config.DbPass, Config.DbName, Config.DbPort)
-	// once.Do(func() {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		panic(err)
+const (
+	DbPostgres = "postgres"
+	DbSqlite   = "sqlite"
+)
+
+// 内存数据库的序号, 每次打开使用不同的共享缓存库
+var memoryDbSeq atomic.Int64
+
+func openDb() (*gorm.DB, error) {
+	switch Config.DbDriver {
+	case "", DbPostgres:
+		dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=disable",
+			Config.DbHost, Config.DbUser, Config.DbPass, Config.DbName, Config.DbPort)
+		if Config.DbTimezone != "" {
+			dsn += " TimeZone=" + Config.DbTimezone
+		}
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	case DbSqlite:
+		db, err := gorm.Open(sqlite.Open(sqliteDsn(Config.DbPath)), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		// 共享缓存的表锁不受busy_timeout控制, 并发访问时直接报错, 所以内存数据库只用一个连接.
+		// 事务中的查询都要使用事务的tx, 在事务中使用Db会一直等待这个连接
+		if Config.DbPath == ":memory:" {
+			sqlDb, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			sqlDb.SetMaxOpenConns(1)
+		}
+		return db, nil
 	}
-	Db = db
-	// Db.AutoMigrate(&model.User{})
-	// Db.AutoMigrate(&model.Task{})
-	if !Db.Migrator().HasTable("users") {
-		slog.Info("迁移用户表")
-		Db.AutoMigrate(&model.User{})
+	return nil, fmt.Errorf("不支持的数据库驱动: %s", Config.DbDriver)
+}
+
+// SQLite连接串. 文件数据库使用WAL, 事务进行中其他连接仍可读, 写操作等待busy_timeout.
+// 内存数据库使用共享缓存, 库在最后一个连接关闭时释放
+func sqliteDsn(path string) string {
+	if path == "" {
+		path = "gookins.db"
 	}
-	if !Db.Migrator().HasTable("tasks") {
-		slog.Info("迁移任务表")
-		Db.AutoMigrate(&model.Task{})
+	if path == ":memory:" {
+		return fmt.Sprintf("file:gookins-%d?mode=memory&cache=shared&_busy_timeout=5000", memoryDbSeq.Add(1))
 	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_journal_mode=WAL&_busy_timeout=5000"
+}
 
-	// })
+// 按配置连接数据库
+func OpenDb() error {
+	db, err := openDb()
+	if err != nil {
+		return err
+	}
+	Db = db
+	return nil
+}
 
+// 启动前准备数据库: 自动迁移(或在manual_migrate时检查是否有未执行的迁移)并确保有管理员.
+// migrate子命令自己执行迁移, 不调用
+func PrepareDb() error {
+	if Config.ManualMigrate {
+		pending, err := PendingMigrations()
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: %d个", ErrMigrationPending, pending)
+		}
+	} else if err := Migrate(); err != nil {
+		return err
+	}
+	BootstrapAdmin()
+	return nil
 }
@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+// 事务外的查询读不到事务中未提交的写入, 事务回滚后也不会留下
+func TestSqliteNoDirtyRead(t *testing.T) {
+	coretest.OpenDb(t)
+	written := make(chan struct{})
+	counted := make(chan int64, 1)
+	go func() {
+		<-written
+		var count int64
+		if err := core.Db.Model(&model.RoleBinding{}).Count(&count).Error; err != nil {
+			t.Error(err)
+		}
+		counted <- count
+	}()
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model.RoleBinding{UserName: "alice", Role: model.RoleDeveloper, Scope: "app/*"}).Error; err != nil {
+			return err
+		}
+		close(written)
+		// 让事务外的查询先开始
+		time.Sleep(100 * time.Millisecond)
+		return gorm.ErrInvalidTransaction
+	})
+	if err != gorm.ErrInvalidTransaction {
+		t.Fatal(err)
+	}
+	select {
+	case count := <-counted:
+		if count != 0 {
+			t.Fatalf("事务外读到%d条未提交的角色绑定", count)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("事务结束后查询仍未返回")
+	}
+}
+
+// 并发写入都落在同一个库里
+func TestSqliteConcurrentWrites(t *testing.T) {
+	coretest.OpenDb(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := core.Db.Create(&model.Build{TaskName: "app/build"}).Error; err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	var count int64
+	if err := core.Db.Model(&model.Build{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 {
+		t.Fatalf("构建数为%d, 应为100", count)
+	}
+}
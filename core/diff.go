@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 行级统一格式diff, 用于流水线变更的审计和版本对比
+
+const (
+	diffContext = 3
+	// 超过该规模时不计算最长公共子序列, 直接整体替换
+	diffMaxCells = 4_000_000
+)
+
+type diffOp struct {
+	kind byte // ' ' 相同, '-' 删除, '+' 新增
+	a, b int  // 该行之前在旧、新文本中的行数
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	var ops []diffOp
+	if n*m > diffMaxCells {
+		for i, line := range a {
+			ops = append(ops, diffOp{kind: '-', a: i, line: line})
+		}
+		for j, line := range b {
+			ops = append(ops, diffOp{kind: '+', a: n, b: j, line: line})
+		}
+		return ops
+	}
+	// lcs[i][j]为a[i:]和b[j:]的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', a: i, b: j, line: a[i]})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', a: i, b: j, line: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', a: i, b: j, line: b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// 生成统一格式diff, 内容相同时返回空字符串
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := diffLines(splitLines(from), splitLines(to))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	prevEnd := 0
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := max(i-diffContext, prevEnd)
+		// 相邻变更之间的相同行不超过两倍上下文时合并为一个块
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = run
+		}
+		writeHunk(&out, ops[start:end])
+		prevEnd, i = end, end
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp) {
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+	aStart, bStart := ops[0].a, ops[0].b
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		out.WriteByte('\n')
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(s ...string) string { return strings.Join(s, "\n") + "\n" }
+	cases := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"相同", "a\n", "a\n", ""},
+		{
+			"相距较远的变更分为两个块",
+			lines("a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n"),
+			lines("a", "B", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "M", "n", "x"),
+			lines("--- old", "+++ new",
+				"@@ -1,5 +1,5 @@", " a", "-b", "+B", " c", " d", " e",
+				"@@ -10,5 +10,6 @@", " j", " k", " l", "-m", "+M", " n", "+x"),
+		},
+		{"全部删除", lines("a", "b"), "", lines("--- old", "+++ new", "@@ -1,2 +0,0 @@", "-a", "-b")},
+		{"从空新增", "", lines("a", "b"), lines("--- old", "+++ new", "@@ -0,0 +1,2 @@", "+a", "+b")},
+		{"末尾缺少换行", "a\nb", "a\nc", lines("--- old", "+++ new", "@@ -1,2 +1,2 @@", " a", "-b", "+c")},
+	}
+	for _, c := range cases {
+		if got := UnifiedDiff("old", "new", c.from, c.to); got != c.want {
+			t.Errorf("%s: diff应为\n%s实际为\n%s", c.name, c.want, got)
+		}
+	}
+}
+
+func TestUnifiedDiffLargeInput(t *testing.T) {
+	// 超过规模上限时整体替换, 仍然是有效的diff
+	var from, to strings.Builder
+	for i := 0; i < 2100; i++ {
+		from.WriteString("old\n")
+		to.WriteString("new\n")
+	}
+	got := UnifiedDiff("old", "new", from.String(), to.String())
+	if !strings.HasPrefix(got, "--- old\n+++ new\n@@ -1,2100 +1,2100 @@\n") {
+		t.Errorf("大文本的diff头部不正确: %.60q", got)
+	}
+	if strings.Count(got, "\n-old") != 2100 || strings.Count(got, "\n+new") != 2100 {
+		t.Error("大文本应删除全部旧行并新增全部新行")
+	}
+}
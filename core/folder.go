@@ -0,0 +1,88 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"gookins/model"
+)
+
+var ErrInvalidPath = errors.New("路径无效: 各级名称不能为空、不能为.或..且不能包含*")
+
+// 校验任务名或目录路径, 各级以"/"分隔
+func ValidatePath(p string) error {
+	if p == "" || strings.Contains(p, "*") {
+		return ErrInvalidPath
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." || strings.TrimSpace(part) != part {
+			return ErrInvalidPath
+		}
+	}
+	return nil
+}
+
+// 所在目录, 顶层为空
+func ParentPath(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// 目录的权限检查对象. 以"/"结尾使角色范围"a/b/*"覆盖目录a/b本身, 顶层目录只检查全局角色
+func FolderScope(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + "/"
+}
+
+// 各级上级目录, 由外到内
+func ancestorFolders(name string) []string {
+	parts := strings.Split(name, "/")
+	folders := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		folders = append(folders, strings.Join(parts[:i], "/"))
+	}
+	return folders
+}
+
+// 凭据对任务可见的范围: 全局、各级上级目录和任务本身
+func scopeChain(name string) []string {
+	return append(append([]string{""}, ancestorFolders(name)...), name)
+}
+
+// 任务从各级目录继承的环境变量和执行节点标签
+func FolderSettings(name string) (map[string]string, string) {
+	paths := ancestorFolders(name)
+	if len(paths) == 0 {
+		return nil, ""
+	}
+	var folders []model.Folder
+	if result := Db.Where("path IN ?", paths).Find(&folders); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ""
+	}
+	sort.Slice(folders, func(i, j int) bool { return len(folders[i].Path) < len(folders[j].Path) })
+	env := map[string]string{}
+	labels := ""
+	for _, folder := range folders {
+		if folder.Env != "" {
+			var vars map[string]string
+			if err := json.Unmarshal([]byte(folder.Env), &vars); err != nil {
+				slog.Error(err.Error())
+			}
+			for key, value := range vars {
+				env[key] = value
+			}
+		}
+		if folder.Labels != "" {
+			labels = folder.Labels
+		}
+	}
+	return env, labels
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	ForgeGithub = "github"
+	ForgeGitlab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+var (
+	ErrUnknownForge = errors.New("未知的代码托管平台")
+)
+
+// 任务访问代码托管平台使用的凭据ID, 在任务可用的凭据范围内查找
+const ForgeTokenCredential = "forge-token"
+
+// 代码托管平台上打开的合并请求
+type ChangeRequest struct {
+	Id     string
+	Branch string
+	Ref    string
+	Sha    string
+}
+
+// 合并请求在仓库中的引用, 未知平台时为空
+func ChangeRef(forge, id string) string {
+	switch forge {
+	case ForgeGithub, ForgeGitea:
+		return "refs/pull/" + id + "/head"
+	case ForgeGitlab:
+		return "refs/merge-requests/" + id + "/head"
+	}
+	return ""
+}
+
+// 平台API地址, 未配置时使用公共服务地址
+func forgeApi(forge, api string) string {
+	if api != "" {
+		return api
+	}
+	switch forge {
+	case ForgeGithub:
+		return "https://api.github.com"
+	case ForgeGitlab:
+		return "https://gitlab.com/api/v4"
+	}
+	return ""
+}
+
+// 访问任务的代码托管平台使用的令牌. 任务可用范围内的forge-token凭据优先;
+// 全局令牌只发往forge_url配置的平台地址(未配置时为公共服务地址),
+// 避免任务配置的API地址拿到全局令牌
+func forgeToken(taskName, forge, reqURL string) string {
+	if taskName != "" {
+		if credential, err := lookupCredential(Db, taskName, ForgeTokenCredential); err == nil {
+			secret, err := DecryptSecret(credential.Secret)
+			if err != nil {
+				slog.Error(fmt.Sprintf("decrypt forge token of task %s: %v", taskName, err))
+				return ""
+			}
+			return secret
+		}
+	}
+	tokenApi := Config.ForgeUrl
+	if tokenApi == "" {
+		tokenApi = forgeApi(forge, "")
+	}
+	if Config.ForgeToken == "" || !sameOrigin(reqURL, tokenApi) {
+		return ""
+	}
+	return Config.ForgeToken
+}
+
+// 两个地址的协议和主机(含端口)是否相同
+func sameOrigin(a, b string) bool {
+	urlA, err := url.Parse(a)
+	if err != nil || urlA.Host == "" {
+		return false
+	}
+	urlB, err := url.Parse(b)
+	if err != nil || urlB.Host == "" {
+		return false
+	}
+	return strings.EqualFold(urlA.Scheme, urlB.Scheme) && strings.EqualFold(urlA.Host, urlB.Host)
+}
+
+// forge为空时是普通的JSON请求, 不携带平台令牌
+func forgeRequest(ctx context.Context, method, forge, token, reqURL string, body any, out any) error {
+	req, err := newForgeRequest(ctx, method, forge, token, reqURL, body)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", method, reqURL, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func newForgeRequest(ctx context.Context, method, forge, token, reqURL string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if forge != "" && token != "" {
+		switch forge {
+		case ForgeGitlab:
+			req.Header.Set("PRIVATE-TOKEN", token)
+		default:
+			req.Header.Set("Authorization", "token "+token)
+		}
+	}
+	return req, nil
+}
+
+// 合并请求列表每页的数量
+const changesPerPage = 50
+
+// 列出任务仓库中打开的合并请求, 逐页读取直到返回空页.
+// 平台的每页上限可能小于changesPerPage, 所以不以返回数量判断最后一页
+func ListChanges(ctx context.Context, taskName, forge, api, repo string) ([]ChangeRequest, error) {
+	api = forgeApi(forge, api)
+	var changes []ChangeRequest
+	seen := make(map[string]bool)
+	for page := 1; ; page++ {
+		var pageChanges []ChangeRequest
+		switch forge {
+		case ForgeGithub, ForgeGitea:
+			var pulls []struct {
+				Number int `json:"number"`
+				Head   struct {
+					Ref string `json:"ref"`
+					Sha string `json:"sha"`
+				} `json:"head"`
+			}
+			// github使用per_page, gitea使用limit
+			reqURL := fmt.Sprintf("%s/repos/%s/pulls?state=open&per_page=%d&limit=%d&page=%d", api, repo, changesPerPage, changesPerPage, page)
+			if err := forgeRequest(ctx, http.MethodGet, forge, forgeToken(taskName, forge, reqURL), reqURL, nil, &pulls); err != nil {
+				return nil, err
+			}
+			for _, pull := range pulls {
+				id := strconv.Itoa(pull.Number)
+				pageChanges = append(pageChanges, ChangeRequest{Id: id, Branch: pull.Head.Ref, Ref: ChangeRef(forge, id), Sha: pull.Head.Sha})
+			}
+		case ForgeGitlab:
+			var mergeRequests []struct {
+				Iid          int    `json:"iid"`
+				SourceBranch string `json:"source_branch"`
+				Sha          string `json:"sha"`
+			}
+			reqURL := fmt.Sprintf("%s/projects/%s/merge_requests?state=opened&per_page=%d&page=%d", api, url.PathEscape(repo), changesPerPage, page)
+			if err := forgeRequest(ctx, http.MethodGet, forge, forgeToken(taskName, forge, reqURL), reqURL, nil, &mergeRequests); err != nil {
+				return nil, err
+			}
+			for _, mr := range mergeRequests {
+				id := strconv.Itoa(mr.Iid)
+				pageChanges = append(pageChanges, ChangeRequest{Id: id, Branch: mr.SourceBranch, Ref: ChangeRef(forge, id), Sha: mr.Sha})
+			}
+		default:
+			return nil, ErrUnknownForge
+		}
+		added := false
+		for _, change := range pageChanges {
+			if !seen[change.Id] {
+				seen[change.Id] = true
+				changes = append(changes, change)
+				added = true
+			}
+		}
+		// 忽略分页参数的服务器会一直返回同一页
+		if !added {
+			return changes, nil
+		}
+	}
+}
+
+// 在代码托管平台上设置任务仓库中提交的状态, state取值与平台一致
+func PostCommitStatus(ctx context.Context, taskName, forge, api, repo, sha, state, targetURL, description, name string) error {
+	api = forgeApi(forge, api)
+	var reqURL string
+	var body map[string]string
+	switch forge {
+	case ForgeGithub, ForgeGitea:
+		reqURL = fmt.Sprintf("%s/repos/%s/statuses/%s", api, repo, sha)
+		body = map[string]string{"state": state, "target_url": targetURL, "description": description, "context": name}
+	case ForgeGitlab:
+		reqURL = fmt.Sprintf("%s/projects/%s/statuses/%s", api, url.PathEscape(repo), sha)
+		body = map[string]string{"state": state, "target_url": targetURL, "description": description, "name": name}
+	default:
+		return ErrUnknownForge
+	}
+	return forgeRequest(ctx, http.MethodPost, forge, forgeToken(taskName, forge, reqURL), reqURL, body, nil)
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"gookins/model"
+)
+
+func TestListChangesPaginates(t *testing.T) {
+	const total = 2*changesPerPage + 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var pulls []map[string]any
+		for i := (page-1)*changesPerPage + 1; i <= page*changesPerPage && i <= total; i++ {
+			pulls = append(pulls, map[string]any{"number": i, "head": map[string]string{"ref": fmt.Sprintf("b%d", i)}})
+		}
+		json.NewEncoder(w).Encode(pulls)
+	}))
+	defer server.Close()
+	changes, err := ListChanges(context.Background(), "", ForgeGithub, server.URL, "o/r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != total || changes[total-1].Id != strconv.Itoa(total) {
+		t.Fatalf("读取到%d个合并请求", len(changes))
+	}
+}
+
+// 忽略分页参数的服务器一直返回同一页, 不能无限请求
+func TestListChangesIgnoredPage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[{"iid": 1, "source_branch": "a"}, {"iid": 2, "source_branch": "b"}]`))
+	}))
+	defer server.Close()
+	changes, err := ListChanges(context.Background(), "", ForgeGitlab, server.URL, "o/r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || requests != 2 {
+		t.Fatalf("读取到%d个合并请求, 请求%d次", len(changes), requests)
+	}
+}
+
+// 全局令牌只发往配置的平台地址, 其他地址使用任务范围的forge-token凭据
+func TestForgeTokenScopedToConfiguredApi(t *testing.T) {
+	openTestDb(t)
+	oldToken, oldUrl, oldKey := Config.ForgeToken, Config.ForgeUrl, Config.MasterKey
+	Config.ForgeToken, Config.ForgeUrl, Config.MasterKey = "global-token", "https://forge.example.com/api/v1", "test-key"
+	t.Cleanup(func() { Config.ForgeToken, Config.ForgeUrl, Config.MasterKey = oldToken, oldUrl, oldKey })
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	if _, err := ListChanges(context.Background(), "app", ForgeGitea, server.URL, "o/r"); err != nil {
+		t.Fatal(err)
+	}
+	if received != "" {
+		t.Fatalf("未配置的平台地址收到令牌 %q", received)
+	}
+	if token := forgeToken("app", ForgeGitea, "https://forge.example.com/api/v1/repos/o/r/pulls"); token != "global-token" {
+		t.Fatalf("配置的平台地址使用令牌 %q", token)
+	}
+
+	createTestCredential(t, model.Credential{CredId: ForgeTokenCredential, Kind: model.CredentialSecret, Scope: "app"}, "task-token")
+	if _, err := ListChanges(context.Background(), "app", ForgeGitea, server.URL, "o/r"); err != nil {
+		t.Fatal(err)
+	}
+	if received != "token task-token" {
+		t.Fatalf("任务凭据令牌为 %q", received)
+	}
+}
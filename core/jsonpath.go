@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 解码JSON, 数字保持原文(json.Number), 避免大整数ID被转换为浮点数后丢失精度或变成科学计数法
+func DecodeJson(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("JSON之后还有多余的内容")
+	}
+	return doc, nil
+}
+
+// 按简单的JSONPath($.a.b[0]['c'])从解码后的JSON中取值
+func JsonPath(doc any, path string) (any, bool) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, false
+	}
+	path = path[1:]
+	current := doc
+	for path != "" {
+		var key string
+		index := -1
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			key, path = path[:end], path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			inner := path[1:end]
+			path = path[end+1:]
+			if unquoted, ok := strings.CutPrefix(inner, "'"); ok {
+				key = strings.TrimSuffix(unquoted, "'")
+			} else if unquoted, ok := strings.CutPrefix(inner, `"`); ok {
+				key = strings.TrimSuffix(unquoted, `"`)
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, false
+				}
+				index = n
+			}
+		default:
+			return nil, false
+		}
+		if index >= 0 {
+			list, ok := current.([]any)
+			if !ok || index >= len(list) {
+				return nil, false
+			}
+			current = list[index]
+			continue
+		}
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// 将JSON值转换为参数字符串, 对象和数组保持JSON格式
+func JsonValueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
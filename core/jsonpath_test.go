@@ -0,0 +1,32 @@
+package core
+
+import "testing"
+
+func TestJsonPathNumbers(t *testing.T) {
+	doc, err := DecodeJson([]byte(`{"id": 12345678901234567890, "pr": {"number": 42, "ratio": 0.5}, "tags": ["a", "b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"$.id":        "12345678901234567890",
+		"$.pr.number": "42",
+		"$.pr.ratio":  "0.5",
+		"$.tags[1]":   "b",
+		"$['tags']":   `["a","b"]`,
+	}
+	for path, want := range cases {
+		value, ok := JsonPath(doc, path)
+		if !ok {
+			t.Fatalf("%s没有取到值", path)
+		}
+		if got := JsonValueString(value); got != want {
+			t.Errorf("%s = %q, 应为%q", path, got, want)
+		}
+	}
+	if _, ok := JsonPath(doc, "$.missing"); ok {
+		t.Error("不存在的路径应取不到值")
+	}
+	if _, err := DecodeJson([]byte(`{} {}`)); err == nil {
+		t.Error("多个JSON值应解码失败")
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+var (
+	ErrLdapDisabled = errors.New("未配置LDAP")
+	ErrLdapUser     = errors.New("LDAP中未找到唯一的用户")
+)
+
+// LDAP认证配置, url为空时不启用.
+// 过滤器中的{username}替换为登录名, group_filter中的{dn}替换为用户DN, 替换值均按RFC4515转义.
+// username_attr为用户条目中的登录名属性, 登录后以该属性的值作为用户名, 而不是输入的登录名
+type ldapConfig struct {
+	Url                string      `yaml:"url"`
+	StartTLS           bool        `yaml:"start_tls"`
+	InsecureSkipVerify bool        `yaml:"insecure_skip_verify"`
+	BindDn             string      `yaml:"bind_dn"`
+	BindPassword       string      `yaml:"bind_password"`
+	BaseDn             string      `yaml:"base_dn"`
+	UserFilter         string      `yaml:"user_filter"`
+	UsernameAttr       string      `yaml:"username_attr"`
+	GroupBaseDn        string      `yaml:"group_base_dn"`
+	GroupFilter        string      `yaml:"group_filter"`
+	GroupAttr          string      `yaml:"group_attr"`
+	GroupRoles         []GroupRole `yaml:"group_roles"`
+}
+
+func ldapDial(cfg ldapConfig) (*ldap.Conn, error) {
+	u, err := url.Parse(cfg.Url)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(cfg.Url, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+	if u.Scheme == "ldap" && cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// 简单绑定, 空密码会被服务端当作匿名绑定, 因此直接拒绝
+func ldapBind(conn *ldap.Conn, dn, password string) error {
+	if password == "" {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("empty password"))
+	}
+	return conn.Bind(dn, password)
+}
+
+// 在base下整个子树中搜索, sizeLimit为0表示不限制
+func ldapSearch(conn *ldap.Conn, base, filter string, attrs []string, sizeLimit int) ([]*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, sizeLimit, int(ldapTimeout/time.Second), false, filter, attrs, nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// 按用户过滤器查找唯一的用户条目, 返回DN和条目中的登录名
+func ldapFindUser(conn *ldap.Conn, cfg ldapConfig, username string) (string, string, error) {
+	userFilter := cfg.UserFilter
+	if userFilter == "" {
+		userFilter = "(uid={username})"
+	}
+	usernameAttr := cfg.UsernameAttr
+	if usernameAttr == "" {
+		usernameAttr = "uid"
+	}
+	filter := strings.ReplaceAll(userFilter, "{username}", ldap.EscapeFilter(username))
+	entries, err := ldapSearch(conn, cfg.BaseDn, filter, []string{usernameAttr}, 2)
+	// 超过一个匹配时服务端返回sizeLimitExceeded
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", "", ErrLdapUser
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if len(entries) != 1 {
+		return "", "", ErrLdapUser
+	}
+	uid := entries[0].GetAttributeValue(usernameAttr)
+	if uid == "" {
+		return "", "", ErrLdapUser
+	}
+	return entries[0].DN, uid, nil
+}
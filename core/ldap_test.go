@@ -0,0 +1,190 @@
+package core
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// 模拟的LDAP服务端: 支持简单绑定和按过滤器字符串返回预设条目的搜索
+type fakeLdap struct {
+	t         *testing.T
+	passwords map[string]string
+	// 过滤器字符串到结果条目
+	results map[string][]*ldap.Entry
+	mu      sync.Mutex
+	filters []string
+}
+
+func newFakeLdap(t *testing.T, passwords map[string]string, results map[string][]*ldap.Entry) (*fakeLdap, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeLdap{t: t, passwords: passwords, results: results}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server, "ldap://" + listener.Addr().String()
+}
+
+func ldapResponse(id int64, tag ber.Tag, children ...*ber.Packet) *ber.Packet {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	for _, child := range children {
+		op.AppendChild(child)
+	}
+	envelope.AppendChild(op)
+	return envelope
+}
+
+func ldapResultCode(code int64) []*ber.Packet {
+	return []*ber.Packet{
+		ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""),
+	}
+}
+
+func (s *fakeLdap) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Value.(string), op.Children[2].Data.String()
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			if want, ok := s.passwords[dn]; ok && want == password {
+				code = ldap.LDAPResultSuccess
+			}
+			responses = append(responses, ldapResponse(id, ldap.ApplicationBindResponse, ldapResultCode(code)...))
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				s.t.Error(err)
+				return
+			}
+			s.mu.Lock()
+			s.filters = append(s.filters, filter)
+			s.mu.Unlock()
+			entries := s.results[filter]
+			code := int64(ldap.LDAPResultSuccess)
+			if limit := op.Children[3].Value.(int64); limit > 0 && int64(len(entries)) > limit {
+				entries, code = entries[:limit], ldap.LDAPResultSizeLimitExceeded
+			}
+			for _, entry := range entries {
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for _, attr := range entry.Attributes {
+					pair := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					pair.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, ""))
+					values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, value := range attr.Values {
+						values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+					}
+					pair.AppendChild(values)
+					attrs.AppendChild(pair)
+				}
+				responses = append(responses, ldapResponse(id, ldap.ApplicationSearchResultEntry,
+					ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""), attrs))
+			}
+			responses = append(responses, ldapResponse(id, ldap.ApplicationSearchResultDone, ldapResultCode(code)...))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+		for _, response := range responses {
+			if _, err := conn.Write(response.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func useLdap(t *testing.T, cfg ldapConfig) {
+	t.Helper()
+	oldConfig := Config.Ldap
+	Config.Ldap = cfg
+	t.Cleanup(func() { Config.Ldap = oldConfig })
+}
+
+func TestLdapAuthenticate(t *testing.T) {
+	aliceDn := "uid=alice,ou=people,dc=example,dc=org"
+	server, addr := newFakeLdap(t, map[string]string{
+		"cn=admin,dc=example,dc=org": "admin-pass",
+		aliceDn:                      "alice-pass",
+	}, map[string][]*ldap.Entry{
+		"(uid=Alice)": {ldap.NewEntry(aliceDn, map[string][]string{"uid": {"alice"}})},
+		"(uid=a\\2a)": nil,
+		"(member=" + aliceDn + ")": {
+			ldap.NewEntry("cn=devs,ou=groups,dc=example,dc=org", map[string][]string{"cn": {"Devs"}}),
+		},
+	})
+	useLdap(t, ldapConfig{
+		Url:          addr,
+		BindDn:       "cn=admin,dc=example,dc=org",
+		BindPassword: "admin-pass",
+		BaseDn:       "ou=people,dc=example,dc=org",
+		GroupBaseDn:  "ou=groups,dc=example,dc=org",
+		GroupFilter:  "(member={dn})",
+		GroupRoles:   []GroupRole{{Group: "devs", Role: "developer", Scope: "app"}},
+	})
+
+	identity, err := ldapAuthenticator{}.Authenticate("Alice", "alice-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 使用目录中的登录名而不是输入的登录名
+	if identity.Username != "alice" {
+		t.Errorf("用户名应为目录中的alice, 实际为%s", identity.Username)
+	}
+	if len(identity.Roles) != 1 || identity.Roles[0].Role != "developer" {
+		t.Errorf("应按组映射得到developer角色, 实际为%v", identity.Roles)
+	}
+
+	if _, err := (ldapAuthenticator{}).Authenticate("Alice", "wrong"); !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("密码错误应绑定失败, 实际为%v", err)
+	}
+	if _, err := (ldapAuthenticator{}).Authenticate("Alice", ""); err == nil {
+		t.Error("空密码不能当作匿名绑定通过")
+	}
+	// 过滤器中的特殊字符被转义
+	if _, err := (ldapAuthenticator{}).Authenticate("a*", "alice-pass"); err != ErrLdapUser {
+		t.Errorf("通配符登录名不应匹配用户, 实际为%v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !strings.Contains(strings.Join(server.filters, " "), "(uid=a\\2a)") {
+		t.Errorf("登录名应按RFC4515转义, 实际的过滤器为%v", server.filters)
+	}
+}
+
+func TestLdapAmbiguousUser(t *testing.T) {
+	_, addr := newFakeLdap(t, nil, map[string][]*ldap.Entry{
+		"(uid=bob)": {
+			ldap.NewEntry("uid=bob,ou=a,dc=example,dc=org", map[string][]string{"uid": {"bob"}}),
+			ldap.NewEntry("uid=bob,ou=b,dc=example,dc=org", map[string][]string{"uid": {"bob"}}),
+		},
+	})
+	useLdap(t, ldapConfig{Url: addr, BaseDn: "dc=example,dc=org"})
+	if _, err := (ldapAuthenticator{}).Authenticate("bob", "pass"); err != ErrLdapUser {
+		t.Errorf("匹配多个用户时应拒绝, 实际为%v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录防暴力破解: 按账号和来源IP分别计数连续失败次数.
+// 每次失败后按指数退避拒绝后续尝试, 达到阈值后锁定一段时间.
+// 账号计数按登录名(不区分大小写)而非用户记录, 不存在的用户同样会被锁定, 避免泄露用户是否存在
+
+const (
+	auditLoginLockout = "login.lockout"
+	auditLoginUnlock  = "login.unlock"
+	// 清理过期计数的间隔
+	loginPruneInterval = time.Minute
+)
+
+var ErrLoginLocked = errors.New("登录尝试过于频繁")
+
+type loginAttempt struct {
+	failures    int
+	lockedUntil time.Time
+	locked      bool
+	last        time.Time
+}
+
+var loginGuard = struct {
+	sync.Mutex
+	accounts  map[string]*loginAttempt
+	ips       map[string]*loginAttempt
+	lastPrune time.Time
+}{accounts: map[string]*loginAttempt{}, ips: map[string]*loginAttempt{}}
+
+func loginLockout() time.Duration {
+	if Config.LoginLockout <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(Config.LoginLockout) * time.Second
+}
+
+func loginMaxFailures(ip bool) int {
+	if ip {
+		if Config.LoginIpMaxFailures <= 0 {
+			return 20
+		}
+		return Config.LoginIpMaxFailures
+	}
+	if Config.LoginMaxFailures <= 0 {
+		return 5
+	}
+	return Config.LoginMaxFailures
+}
+
+// 第n次失败后的退避时间, 不超过锁定时间
+func loginBackoff(failures int) time.Duration {
+	base := time.Duration(Config.LoginBackoffBase) * time.Second
+	if base <= 0 {
+		base = time.Second
+	}
+	backoff := base << min(failures-1, 20)
+	return min(backoff, loginLockout())
+}
+
+// 账号计数的键, 登录名不区分大小写, 避免改变大小写绕过锁定
+func loginKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// 登录前检查并预占一次尝试: 被退避或锁定时返回还需等待的时间,
+// 否则先按失败计数, 认证成功后由LoginSucceeded释放. 并发的登录请求因此不能同时通过检查
+func CheckLogin(ip, username string) (time.Duration, error) {
+	key := loginKey(username)
+	loginGuard.Lock()
+	now := time.Now()
+	var wait time.Duration
+	for _, attempt := range []*loginAttempt{loginGuard.ips[ip], loginGuard.accounts[key]} {
+		if attempt != nil && attempt.lockedUntil.After(now) {
+			wait = max(wait, attempt.lockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		loginGuard.Unlock()
+		return wait.Round(time.Second), ErrLoginLocked
+	}
+	pruneLoginAttempts(now)
+	var events [][2]string
+	if fail(loginGuard.ips, ip, loginMaxFailures(true), now) {
+		events = append(events, [2]string{"ip:" + ip, fmt.Sprintf("来源IP连续登录失败%d次", loginMaxFailures(true))})
+	}
+	if fail(loginGuard.accounts, key, loginMaxFailures(false), now) {
+		events = append(events, [2]string{"user:" + key, fmt.Sprintf("账号连续登录失败%d次", loginMaxFailures(false))})
+	}
+	loginGuard.Unlock()
+	for _, event := range events {
+		Audit(AuditSystem, auditLoginLockout, event[0], ip, fmt.Sprintf("%s, 锁定%s", event[1], loginLockout()))
+	}
+	return 0, nil
+}
+
+// 返回是否刚刚进入锁定状态
+func fail(attempts map[string]*loginAttempt, key string, maxFailures int, now time.Time) bool {
+	attempt, ok := attempts[key]
+	// 锁定结束后重新计数
+	if !ok || (attempt.locked && attempt.lockedUntil.Before(now)) {
+		attempt = &loginAttempt{}
+		attempts[key] = attempt
+	}
+	attempt.failures++
+	attempt.last = now
+	if attempt.failures >= maxFailures {
+		justLocked := !attempt.locked
+		attempt.locked = true
+		attempt.lockedUntil = now.Add(loginLockout())
+		return justLocked
+	}
+	attempt.lockedUntil = now.Add(loginBackoff(attempt.failures))
+	return false
+}
+
+// 登录成功释放预占的尝试: 清除账号计数, usernames为输入的登录名和认证后端确认的用户名.
+// IP计数只撤销本次预占, 之前的失败保留, 避免攻击者用自己的账号重置
+func LoginSucceeded(ip string, usernames ...string) {
+	loginGuard.Lock()
+	defer loginGuard.Unlock()
+	for _, username := range usernames {
+		delete(loginGuard.accounts, loginKey(username))
+	}
+	attempt, ok := loginGuard.ips[ip]
+	if !ok || attempt.locked {
+		return
+	}
+	attempt.failures--
+	if attempt.failures <= 0 {
+		delete(loginGuard.ips, ip)
+		return
+	}
+	attempt.lockedUntil = attempt.last.Add(loginBackoff(attempt.failures))
+}
+
+// 管理员手动解锁账号, 返回账号此前是否处于锁定状态
+func UnlockLogin(actor, username, ip string) bool {
+	key := loginKey(username)
+	loginGuard.Lock()
+	attempt, ok := loginGuard.accounts[key]
+	locked := ok && attempt.locked && attempt.lockedUntil.After(time.Now())
+	delete(loginGuard.accounts, key)
+	loginGuard.Unlock()
+	if locked {
+		Audit(actor, auditLoginUnlock, "user:"+username, ip, "手动解锁")
+	}
+	return locked
+}
+
+// 清理已过锁定期且长时间没有失败的计数, 调用方持有锁
+func pruneLoginAttempts(now time.Time) {
+	if now.Sub(loginGuard.lastPrune) < loginPruneInterval {
+		return
+	}
+	loginGuard.lastPrune = now
+	var unlocked []string
+	for name, attempts := range map[string]map[string]*loginAttempt{"ip:": loginGuard.ips, "user:": loginGuard.accounts} {
+		for key, attempt := range attempts {
+			if attempt.lockedUntil.After(now) || now.Sub(attempt.last) < loginLockout() {
+				continue
+			}
+			if attempt.locked {
+				unlocked = append(unlocked, name+key)
+			}
+			delete(attempts, key)
+		}
+	}
+	// 锁定自然到期, 异步写审计避免持锁访问数据库
+	if len(unlocked) > 0 {
+		go func() {
+			for _, target := range unlocked {
+				Audit(AuditSystem, auditLoginUnlock, target, "", "锁定到期")
+			}
+		}()
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func resetLoginGuard(t *testing.T) {
+	t.Helper()
+	old := Config.LoginMaxFailures
+	Config.LoginMaxFailures = 3
+	loginGuard.Lock()
+	loginGuard.accounts, loginGuard.ips = map[string]*loginAttempt{}, map[string]*loginAttempt{}
+	loginGuard.Unlock()
+	t.Cleanup(func() { Config.LoginMaxFailures = old })
+}
+
+func TestCheckLoginReservesAttempt(t *testing.T) {
+	openTestDb(t)
+	resetLoginGuard(t)
+	// 并发的尝试中只有一个能通过检查, 其余被退避拒绝
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	passed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := CheckLogin("10.0.0.1", "alice"); err == nil {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if passed != 1 {
+		t.Errorf("并发登录应只有一次通过检查, 实际为%d", passed)
+	}
+}
+
+func TestCheckLoginCaseFolded(t *testing.T) {
+	openTestDb(t)
+	resetLoginGuard(t)
+	if _, err := CheckLogin("10.0.0.1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// 改变大小写和IP不能绕过账号退避
+	if _, err := CheckLogin("10.0.0.2", " ALICE"); err != ErrLoginLocked {
+		t.Errorf("不同大小写的登录名应共用计数, 实际为%v", err)
+	}
+}
+
+func TestLoginSucceededReleases(t *testing.T) {
+	openTestDb(t)
+	resetLoginGuard(t)
+	if _, err := CheckLogin("10.0.0.1", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	LoginSucceeded("10.0.0.1", "Alice", "alice")
+	if _, err := CheckLogin("10.0.0.1", "alice"); err != nil {
+		t.Errorf("登录成功后应释放预占的尝试, 实际为%v", err)
+	}
+	loginGuard.Lock()
+	failures := loginGuard.ips["10.0.0.1"].failures
+	loginGuard.Unlock()
+	if failures != 1 {
+		t.Errorf("IP计数应只包含未释放的尝试, 实际为%d", failures)
+	}
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"gookins/model"
+)
+
+// 替换秘密值的掩码
+const secretMask = "****"
+
+// 秘密值及其base64和URL编码形式
+func secretForms(secrets []string) [][]byte {
+	seen := make(map[string]bool)
+	var forms [][]byte
+	add := func(s string) {
+		if s == "" || seen[s] {
+			return
+		}
+		seen[s] = true
+		forms = append(forms, []byte(s))
+	}
+	for _, secret := range secrets {
+		add(secret)
+		add(base64.StdEncoding.EncodeToString([]byte(secret)))
+		add(base64.RawStdEncoding.EncodeToString([]byte(secret)))
+		add(base64.URLEncoding.EncodeToString([]byte(secret)))
+		add(base64.RawURLEncoding.EncodeToString([]byte(secret)))
+		add(url.QueryEscape(secret))
+		add(url.PathEscape(secret))
+	}
+	return forms
+}
+
+// 流式掩码过滤器: 将写入内容中的秘密值替换为****后写入下游.
+// 为处理跨越多次Write的秘密值, 末尾可能是秘密值前缀的部分会暂存到下一次Write或Close
+type MaskWriter struct {
+	w       io.Writer
+	secrets [][]byte
+	maxLen  int
+	buf     []byte
+}
+
+func NewMaskWriter(w io.Writer, secrets []string) *MaskWriter {
+	mw := &MaskWriter{w: w, secrets: secretForms(secrets)}
+	for _, secret := range mw.secrets {
+		if len(secret) > mw.maxLen {
+			mw.maxLen = len(secret)
+		}
+	}
+	return mw
+}
+
+// 查找最早出现的秘密值, 同一位置取最长的
+func (mw *MaskWriter) nextSecret() (int, int) {
+	index, length := -1, 0
+	for _, secret := range mw.secrets {
+		i := bytes.Index(mw.buf, secret)
+		if i < 0 {
+			continue
+		}
+		if index < 0 || i < index || (i == index && len(secret) > length) {
+			index, length = i, len(secret)
+		}
+	}
+	return index, length
+}
+
+// buf[index:]是否可能是比length更长的秘密值的前缀, 是则需要等待更多内容再决定
+func (mw *MaskWriter) longerPending(index, length int) bool {
+	rest := mw.buf[index:]
+	for _, secret := range mw.secrets {
+		if len(secret) > length && len(rest) < len(secret) && bytes.HasPrefix(secret, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// 替换暂存内容中的秘密值并写出, final为false时保留末尾可能是秘密值前缀的部分
+func (mw *MaskWriter) flush(final bool) error {
+	pending := -1
+	for {
+		index, length := mw.nextSecret()
+		if index < 0 {
+			break
+		}
+		if !final && mw.longerPending(index, length) {
+			pending = index
+			break
+		}
+		if _, err := mw.w.Write(append(mw.buf[:index:index], secretMask...)); err != nil {
+			return err
+		}
+		mw.buf = mw.buf[index+length:]
+	}
+	n := len(mw.buf)
+	if !final {
+		n -= mw.maxLen - 1
+		if pending >= 0 && pending < n {
+			n = pending
+		}
+	}
+	if n <= 0 {
+		return nil
+	}
+	if _, err := mw.w.Write(mw.buf[:n]); err != nil {
+		return err
+	}
+	mw.buf = append([]byte(nil), mw.buf[n:]...)
+	return nil
+}
+
+func (mw *MaskWriter) Write(p []byte) (int, error) {
+	if len(mw.secrets) == 0 {
+		return mw.w.Write(p)
+	}
+	mw.buf = append(mw.buf, p...)
+	if err := mw.flush(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// 写出暂存的内容
+func (mw *MaskWriter) Close() error {
+	if len(mw.buf) == 0 {
+		return nil
+	}
+	err := mw.flush(true)
+	mw.buf = nil
+	return err
+}
+
+// 任务可见的所有凭据的明文, 用于掩码
+func knownSecrets(taskName string) []string {
+	var credentials []model.Credential
+	if result := Db.Where("scope IN ?", scopeChain(taskName)).Find(&credentials); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil
+	}
+	var secrets []string
+	for _, credential := range credentials {
+		secrets = append(secrets, credentialSecrets(credential)...)
+	}
+	return secrets
+}
+
+// 需要掩码的凭据明文, 解密失败时为空
+func credentialSecrets(credential model.Credential) []string {
+	secret, err := DecryptSecret(credential.Secret)
+	if err != nil {
+		return nil
+	}
+	secrets := []string{secret}
+	// 多行的密钥文件按行掩码, 避免逐行输出时泄露
+	for _, line := range strings.Split(secret, "\n") {
+		if len(strings.TrimSpace(line)) >= 8 {
+			secrets = append(secrets, line)
+		}
+	}
+	return secrets
+}
+
+// 按范围分组的凭据明文. 一次请求中掩码多个任务时只查询和解密一次所有凭据,
+// 而不是每个任务各自查询和解密
+type SecretCache struct {
+	byScope map[string][]string
+}
+
+func LoadSecrets() *SecretCache {
+	cache := &SecretCache{byScope: make(map[string][]string)}
+	var credentials []model.Credential
+	if result := Db.Find(&credentials); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return cache
+	}
+	for _, credential := range credentials {
+		cache.byScope[credential.Scope] = append(cache.byScope[credential.Scope], credentialSecrets(credential)...)
+	}
+	return cache
+}
+
+// 掩码字符串中任务可见的凭据, 与MaskSecrets相同
+func (c *SecretCache) Mask(taskName, s string) string {
+	var secrets []string
+	for _, scope := range scopeChain(taskName) {
+		secrets = append(secrets, c.byScope[scope]...)
+	}
+	return maskString(secrets, s)
+}
+
+// 掩码字符串中的秘密值
+func maskString(secrets []string, s string) string {
+	var out strings.Builder
+	mw := NewMaskWriter(&out, secrets)
+	mw.Write([]byte(s))
+	mw.Close()
+	return out.String()
+}
+
+// 掩码字符串中任务可见的凭据, 用于接口响应
+func MaskSecrets(taskName, s string) string {
+	return maskString(knownSecrets(taskName), s)
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gookins/model"
+)
+
+func TestMaskWriterSplitWrites(t *testing.T) {
+	secret := "s3cr3t-value"
+	input := "token=" + secret + " b64=" + base64.StdEncoding.EncodeToString([]byte(secret)) + " url=" + url.QueryEscape(secret+"&x") + "\n"
+	// 逐字节写入, 秘密值跨越多次Write
+	var out bytes.Buffer
+	mw := NewMaskWriter(&out, []string{secret, secret + "&x"})
+	for i := 0; i < len(input); i++ {
+		if _, err := mw.Write([]byte{input[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mw.Close()
+	want := "token=**** b64=**** url=****\n"
+	if out.String() != want {
+		t.Errorf("掩码结果应为%q, 实际为%q", want, out.String())
+	}
+}
+
+func TestMaskWriterNoSecrets(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewMaskWriter(&out, nil)
+	mw.Write([]byte("plain output"))
+	mw.Close()
+	if out.String() != "plain output" {
+		t.Errorf("没有秘密值时应原样输出, 实际为%q", out.String())
+	}
+}
+
+func TestMaskSecretsMultiline(t *testing.T) {
+	openTestDb(t)
+	oldKey := Config.MasterKey
+	Config.MasterKey = "test-key"
+	t.Cleanup(func() { Config.MasterKey = oldKey })
+	createTestCredential(t, model.Credential{CredId: "key", Kind: model.CredentialSshKey, Scope: "app"}, "-----BEGIN KEY-----\nAAAABBBBCCCCDDDD\n-----END KEY-----")
+	createTestCredential(t, model.Credential{CredId: "other", Kind: model.CredentialSecret, Scope: "other"}, "other-secret")
+
+	got := MaskSecrets("app", "line AAAABBBBCCCCDDDD other-secret")
+	if got != "line **** other-secret" {
+		t.Errorf("应只掩码任务可见的凭据的每一行, 实际为%q", got)
+	}
+	// 一次加载的缓存按各任务的范围掩码, 结果与MaskSecrets相同
+	secrets := LoadSecrets()
+	if got := secrets.Mask("app", "line AAAABBBBCCCCDDDD other-secret"); got != "line **** other-secret" {
+		t.Errorf("缓存掩码任务app的结果为%q", got)
+	}
+	if got := secrets.Mask("other", "line AAAABBBBCCCCDDDD other-secret"); got != "line AAAABBBBCCCCDDDD ****" {
+		t.Errorf("缓存掩码任务other的结果为%q", got)
+	}
+}
+
+func TestRunCommandMasksLogs(t *testing.T) {
+	tp := newTestPool(t, 1, "")
+	oldKey := Config.MasterKey
+	Config.MasterKey = "test-key"
+	t.Cleanup(func() { Config.MasterKey = oldKey })
+	createTestCredential(t, model.Credential{CredId: "token", Kind: model.CredentialSecret}, "pasted-token-123")
+
+	var logs bytes.Buffer
+	oldLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(oldLogger) })
+
+	task := &TaskJob{Name: "app"}
+	err := tp.runCommand(context.Background(), task, step{Name: "echo", Command: "echo pasted-token-123"}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "pasted-token-123") {
+		t.Errorf("日志中不应出现凭据明文: %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "echo ****") {
+		t.Errorf("日志中的命令应被掩码: %s", logs.String())
+	}
+}
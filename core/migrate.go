@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMigrationPending = errors.New("有未执行的数据库迁移, 请先运行 gookins migrate")
+)
+
+// postgres advisory锁的键, 连接同一数据库的多个实例依次迁移
+const migrationLockKey int64 = 0x676f6f6b696e73
+
+// 一个版本的数据库迁移, up和down在同一个事务中与迁移记录一起提交
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+func (m migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.version, m.name)
+}
+
+// 持有迁移锁并确保迁移记录表存在后执行fn. advisory锁属于数据库会话, 所以固定使用一个连接
+func withMigrationLock(fn func(conn *gorm.DB) error) error {
+	return Db.Connection(func(conn *gorm.DB) error {
+		// 新会话使每次链式调用都从空语句开始, 否则前一次查询的表名和条件会留在conn上
+		conn = conn.Session(&gorm.Session{})
+		if conn.Dialector.Name() == "postgres" {
+			var locked bool
+			if err := conn.Raw("SELECT pg_try_advisory_lock(?)", migrationLockKey).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if !locked {
+				slog.Info("等待其他实例完成数据库迁移")
+				if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+					return err
+				}
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+		}
+		if !conn.Migrator().HasTable(&model.SchemaMigration{}) {
+			if err := conn.Migrator().CreateTable(&model.SchemaMigration{}); err != nil {
+				return err
+			}
+		}
+		return fn(conn)
+	})
+}
+
+// 已执行的迁移, 按版本号索引
+func appliedMigrations(db *gorm.DB) (map[int]model.SchemaMigration, error) {
+	applied := map[int]model.SchemaMigration{}
+	if !db.Migrator().HasTable(&model.SchemaMigration{}) {
+		return applied, nil
+	}
+	var rows []model.SchemaMigration
+	if result := db.Order("version").Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// 数据库中有本程序不认识的迁移时, 通常是更新版本的程序执行过迁移后又回退了程序
+func warnUnknownMigrations(applied map[int]model.SchemaMigration) {
+	known := map[int]bool{}
+	for _, m := range migrations {
+		known[m.version] = true
+	}
+	for version, row := range applied {
+		if !known[version] {
+			slog.Warn(fmt.Sprintf("数据库包含本程序未知的迁移%04d_%s", version, row.Name))
+		}
+	}
+}
+
+// 按版本号顺序执行所有未执行的迁移
+func Migrate() error {
+	return withMigrationLock(func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		warnUnknownMigrations(applied)
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok {
+				continue
+			}
+			slog.Info("执行数据库迁移" + m.String())
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&model.SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("数据库迁移%s失败: %w", m, err)
+			}
+		}
+		return nil
+	})
+}
+
+// 按版本号倒序回滚最近执行的steps个迁移
+func MigrateDown(steps int) error {
+	return withMigrationLock(func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			slog.Info("回滚数据库迁移" + m.String())
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&model.SchemaMigration{}, m.version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("回滚数据库迁移%s失败: %w", m, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// 所有迁移及其执行时间, 包括数据库中本程序未知的迁移
+func MigrationStatuses() ([]model.MigrationStatus, error) {
+	applied, err := appliedMigrations(Db)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []model.MigrationStatus
+	for _, m := range migrations {
+		status := model.MigrationStatus{Version: m.version, Name: m.name}
+		if row, ok := applied[m.version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(applied, m.version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, model.MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &row.AppliedAt})
+	}
+	return statuses, nil
+}
+
+// 未执行的迁移数量
+func PendingMigrations() (int, error) {
+	applied, err := appliedMigrations(Db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,741 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据库迁移, 按版本号递增追加, 不要修改或删除已发布的迁移.
+// 每个迁移只使用下面定义的表结构快照, 不引用model包中的模型, 模型之后的修改不会改变已发布迁移的结果.
+// 0.x版本只在表不存在时建表, 已部署的数据库可能缺少任意的列, 所以建表、加列和建索引前都要检查是否已存在
+var migrations = []migration{
+	{1, "create_users_tasks", createUsersTasks, dropUsersTasks},
+	{2, "create_pool_states", createPoolStates, dropPoolStates},
+	{3, "create_builds", createBuilds, dropBuilds},
+	{4, "add_repo_pipeline", addRepoPipeline, dropRepoPipeline},
+	{5, "add_multibranch", addMultibranch, dropMultibranch},
+	{6, "add_scm_polling", addScmPolling, dropScmPolling},
+	{7, "add_task_triggers", addTaskTriggers, dropTaskTriggers},
+	{8, "add_webhook_trigger", addWebhookTrigger, dropWebhookTrigger},
+	{9, "create_approvals", createApprovals, dropApprovals},
+	{10, "create_credentials", createCredentials, dropCredentials},
+	{11, "create_role_bindings", createRoleBindings, dropRoleBindings},
+	{12, "create_api_tokens", createApiTokens, dropApiTokens},
+	{13, "create_sessions", createSessions, dropSessions},
+	{14, "add_user_source", addUserSource, dropUserSource},
+	{15, "create_audit_logs", createAuditLogs, dropAuditLogs},
+	{16, "alter_audit_logs", alterAuditLogs, revertAuditLogs},
+	{17, "create_task_revisions", createTaskRevisions, dropTaskRevisions},
+	{18, "create_folders", createFolders, dropFolders},
+	{19, "add_build_requeue", addBuildRequeue, dropBuildRequeue},
+	{20, "hash_webhook_tokens", hashWebhookTokens, unhashWebhookTokens},
+	{21, "add_build_resume_step", addBuildResumeStep, dropBuildResumeStep},
+	{22, "unique_user_names", uniqueUserNames, dropUniqueUserNames},
+	{23, "add_user_subject", addUserSubject, dropUserSubject},
+	{24, "unique_task_names", uniqueTaskNames, dropUniqueTaskNames},
+}
+
+func createTables(tx *gorm.DB, values ...any) error {
+	migrator := tx.Migrator()
+	for _, value := range values {
+		if migrator.HasTable(value) {
+			continue
+		}
+		if err := migrator.CreateTable(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropTables(tx *gorm.DB, values ...any) error {
+	for i := len(values) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addColumns(tx *gorm.DB, value any, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if migrator.HasColumn(value, field) {
+			continue
+		}
+		if err := migrator.AddColumn(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropColumns(tx *gorm.DB, value any, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if !migrator.HasColumn(value, field) {
+			continue
+		}
+		if err := migrator.DropColumn(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按字段名创建快照中定义的索引
+func createIndexes(tx *gorm.DB, value any, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if migrator.HasIndex(value, field) {
+			continue
+		}
+		if err := migrator.CreateIndex(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, value any, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if !migrator.HasIndex(value, field) {
+			continue
+		}
+		if err := migrator.DropIndex(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 0001: 最初的用户表和任务表
+type userV1 struct {
+	gorm.Model
+	Name     string `gorm:"name"`
+	Password string `gorm:"password"`
+	Avatar   string `gorm:"avatar"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type taskV1 struct {
+	gorm.Model
+	Name        string `gorm:"name"`
+	Description string
+	PipeLine    string `gorm:"pipeline;type:text"`
+	Disabled    bool   `gorm:"disabled;default:false"`
+}
+
+func (taskV1) TableName() string { return "tasks" }
+
+func createUsersTasks(tx *gorm.DB) error {
+	return createTables(tx, &userV1{}, &taskV1{})
+}
+
+func dropUsersTasks(tx *gorm.DB) error {
+	return dropTables(tx, &userV1{}, &taskV1{})
+}
+
+// 0002: 任务池运行时状态
+type poolStateV2 struct {
+	gorm.Model
+	Paused      bool `gorm:"paused;default:false"`
+	Draining    bool `gorm:"draining;default:false"`
+	WorkerCount int  `gorm:"worker_count"`
+}
+
+func (poolStateV2) TableName() string { return "pool_states" }
+
+func createPoolStates(tx *gorm.DB) error {
+	return createTables(tx, &poolStateV2{})
+}
+
+func dropPoolStates(tx *gorm.DB) error {
+	return dropTables(tx, &poolStateV2{})
+}
+
+// 0003: 构建记录
+type buildV3 struct {
+	gorm.Model
+	TaskName   string     `gorm:"task_name;index"`
+	PipeLine   string     `gorm:"pipeline;type:text"`
+	State      string     `gorm:"state;index"`
+	StartedAt  *time.Time `gorm:"started_at"`
+	FinishedAt *time.Time `gorm:"finished_at"`
+}
+
+func (buildV3) TableName() string { return "builds" }
+
+func createBuilds(tx *gorm.DB) error {
+	return createTables(tx, &buildV3{})
+}
+
+func dropBuilds(tx *gorm.DB) error {
+	return dropTables(tx, &buildV3{})
+}
+
+// 0004: 从仓库读取流水线
+type taskV4 struct {
+	Repo         string `gorm:"repo"`
+	Ref          string `gorm:"ref"`
+	PipelinePath string `gorm:"pipeline_path"`
+}
+
+func (taskV4) TableName() string { return "tasks" }
+
+type buildV4 struct {
+	Repo         string `gorm:"repo"`
+	Ref          string `gorm:"ref"`
+	PipelinePath string `gorm:"pipeline_path"`
+	Commit       string `gorm:"commit"`
+}
+
+func (buildV4) TableName() string { return "builds" }
+
+func addRepoPipeline(tx *gorm.DB) error {
+	if err := addColumns(tx, &taskV4{}, "Repo", "Ref", "PipelinePath"); err != nil {
+		return err
+	}
+	return addColumns(tx, &buildV4{}, "Repo", "Ref", "PipelinePath", "Commit")
+}
+
+func dropRepoPipeline(tx *gorm.DB) error {
+	if err := dropColumns(tx, &buildV4{}, "Repo", "Ref", "PipelinePath", "Commit"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &taskV4{}, "Repo", "Ref", "PipelinePath")
+}
+
+// 0005: 多分支任务
+type taskV5 struct {
+	Kind      string `gorm:"kind"`
+	ParentId  uint   `gorm:"parent_id;index"`
+	Branch    string `gorm:"branch"`
+	ChangeId  string `gorm:"change_id"`
+	Forge     string `gorm:"forge"`
+	ForgeApi  string `gorm:"forge_api"`
+	ForgeRepo string `gorm:"forge_repo"`
+}
+
+func (taskV5) TableName() string { return "tasks" }
+
+type buildV5 struct {
+	Branch   string `gorm:"branch"`
+	ChangeId string `gorm:"change_id"`
+}
+
+func (buildV5) TableName() string { return "builds" }
+
+var taskColumnsV5 = []string{"Kind", "ParentId", "Branch", "ChangeId", "Forge", "ForgeApi", "ForgeRepo"}
+
+func addMultibranch(tx *gorm.DB) error {
+	if err := addColumns(tx, &taskV5{}, taskColumnsV5...); err != nil {
+		return err
+	}
+	if err := createIndexes(tx, &taskV5{}, "ParentId"); err != nil {
+		return err
+	}
+	return addColumns(tx, &buildV5{}, "Branch", "ChangeId")
+}
+
+func dropMultibranch(tx *gorm.DB) error {
+	if err := dropColumns(tx, &buildV5{}, "Branch", "ChangeId"); err != nil {
+		return err
+	}
+	if err := dropIndexes(tx, &taskV5{}, "ParentId"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &taskV5{}, taskColumnsV5...)
+}
+
+// 0006: 轮询触发
+type taskV6 struct {
+	PollBranches string `gorm:"poll_branches"`
+	PollInterval int    `gorm:"poll_interval"`
+}
+
+func (taskV6) TableName() string { return "tasks" }
+
+type pollStateV6 struct {
+	gorm.Model
+	TaskId uint   `gorm:"task_id;index"`
+	Branch string `gorm:"branch"`
+	Sha    string `gorm:"sha"`
+}
+
+func (pollStateV6) TableName() string { return "poll_states" }
+
+func addScmPolling(tx *gorm.DB) error {
+	if err := addColumns(tx, &taskV6{}, "PollBranches", "PollInterval"); err != nil {
+		return err
+	}
+	return createTables(tx, &pollStateV6{})
+}
+
+func dropScmPolling(tx *gorm.DB) error {
+	if err := dropTables(tx, &pollStateV6{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, &taskV6{}, "PollBranches", "PollInterval")
+}
+
+// 0007: 上游触发和触发链
+type taskV7 struct {
+	TriggerAfter string `gorm:"trigger_after"`
+}
+
+func (taskV7) TableName() string { return "tasks" }
+
+type buildV7 struct {
+	Params string `gorm:"params;type:text"`
+	Causes string `gorm:"causes;type:text"`
+}
+
+func (buildV7) TableName() string { return "builds" }
+
+func addTaskTriggers(tx *gorm.DB) error {
+	if err := addColumns(tx, &taskV7{}, "TriggerAfter"); err != nil {
+		return err
+	}
+	return addColumns(tx, &buildV7{}, "Params", "Causes")
+}
+
+func dropTaskTriggers(tx *gorm.DB) error {
+	if err := dropColumns(tx, &buildV7{}, "Params", "Causes"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &taskV7{}, "TriggerAfter")
+}
+
+// 0008: 通用webhook触发
+type taskV8 struct {
+	WebhookToken      string `gorm:"webhook_token;index"`
+	WebhookParams     string `gorm:"webhook_params;type:text"`
+	WebhookFilter     string `gorm:"webhook_filter"`
+	WebhookFilterText string `gorm:"webhook_filter_text"`
+}
+
+func (taskV8) TableName() string { return "tasks" }
+
+var taskColumnsV8 = []string{"WebhookToken", "WebhookParams", "WebhookFilter", "WebhookFilterText"}
+
+func addWebhookTrigger(tx *gorm.DB) error {
+	if err := addColumns(tx, &taskV8{}, taskColumnsV8...); err != nil {
+		return err
+	}
+	return createIndexes(tx, &taskV8{}, "WebhookToken")
+}
+
+func dropWebhookTrigger(tx *gorm.DB) error {
+	if err := dropIndexes(tx, &taskV8{}, "WebhookToken"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &taskV8{}, taskColumnsV8...)
+}
+
+// 0009: 人工审批
+type buildV9 struct {
+	Approver string `gorm:"approver"`
+}
+
+func (buildV9) TableName() string { return "builds" }
+
+type approvalV9 struct {
+	gorm.Model
+	BuildId   uint64     `gorm:"build_id;index"`
+	TaskName  string     `gorm:"task_name"`
+	Step      int        `gorm:"step"`
+	StepName  string     `gorm:"step_name"`
+	Message   string     `gorm:"message"`
+	Approvers string     `gorm:"approvers"`
+	State     string     `gorm:"state;index"`
+	DecidedBy string     `gorm:"decided_by"`
+	DecidedAt *time.Time `gorm:"decided_at"`
+	Deadline  *time.Time `gorm:"deadline"`
+}
+
+func (approvalV9) TableName() string { return "approvals" }
+
+func createApprovals(tx *gorm.DB) error {
+	if err := addColumns(tx, &buildV9{}, "Approver"); err != nil {
+		return err
+	}
+	return createTables(tx, &approvalV9{})
+}
+
+func dropApprovals(tx *gorm.DB) error {
+	if err := dropTables(tx, &approvalV9{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, &buildV9{}, "Approver")
+}
+
+// 0010: 凭据
+type credentialV10 struct {
+	gorm.Model
+	CredId      string `gorm:"cred_id;index"`
+	Kind        string `gorm:"kind"`
+	Scope       string `gorm:"scope"`
+	Description string `gorm:"description"`
+	Username    string `gorm:"username"`
+	FileName    string `gorm:"file_name"`
+	Secret      string `gorm:"secret;type:text"`
+}
+
+func (credentialV10) TableName() string { return "credentials" }
+
+func createCredentials(tx *gorm.DB) error {
+	return createTables(tx, &credentialV10{})
+}
+
+func dropCredentials(tx *gorm.DB) error {
+	return dropTables(tx, &credentialV10{})
+}
+
+// 0011: 角色绑定
+type roleBindingV11 struct {
+	gorm.Model
+	UserName string `gorm:"user_name;index"`
+	Role     string `gorm:"role"`
+	Scope    string `gorm:"scope"`
+}
+
+func (roleBindingV11) TableName() string { return "role_bindings" }
+
+func createRoleBindings(tx *gorm.DB) error {
+	return createTables(tx, &roleBindingV11{})
+}
+
+func dropRoleBindings(tx *gorm.DB) error {
+	return dropTables(tx, &roleBindingV11{})
+}
+
+// 0012: 个人API令牌
+type apiTokenV12 struct {
+	gorm.Model
+	UserName   string     `gorm:"user_name;index"`
+	Name       string     `gorm:"name"`
+	Prefix     string     `gorm:"prefix"`
+	TokenHash  string     `gorm:"token_hash;uniqueIndex"`
+	Scope      string     `gorm:"scope"`
+	ExpiresAt  *time.Time `gorm:"expires_at"`
+	LastUsedAt *time.Time `gorm:"last_used_at"`
+}
+
+func (apiTokenV12) TableName() string { return "api_tokens" }
+
+func createApiTokens(tx *gorm.DB) error {
+	return createTables(tx, &apiTokenV12{})
+}
+
+func dropApiTokens(tx *gorm.DB) error {
+	return dropTables(tx, &apiTokenV12{})
+}
+
+// 0013: 登录会话和用户禁用
+type userV13 struct {
+	Disabled bool `gorm:"disabled;default:false"`
+}
+
+func (userV13) TableName() string { return "users" }
+
+type sessionV13 struct {
+	gorm.Model
+	UserName    string    `gorm:"user_name;index"`
+	RefreshHash string    `gorm:"refresh_hash;uniqueIndex"`
+	PrevHash    string    `gorm:"prev_hash;index"`
+	ExpiresAt   time.Time `gorm:"expires_at"`
+}
+
+func (sessionV13) TableName() string { return "sessions" }
+
+func createSessions(tx *gorm.DB) error {
+	if err := addColumns(tx, &userV13{}, "Disabled"); err != nil {
+		return err
+	}
+	return createTables(tx, &sessionV13{})
+}
+
+func dropSessions(tx *gorm.DB) error {
+	if err := dropTables(tx, &sessionV13{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, &userV13{}, "Disabled")
+}
+
+// 0014: 外部身份源的用户和角色绑定来源
+type userV14 struct {
+	Source string `gorm:"source;default:local"`
+}
+
+func (userV14) TableName() string { return "users" }
+
+type roleBindingV14 struct {
+	Source string `gorm:"source"`
+}
+
+func (roleBindingV14) TableName() string { return "role_bindings" }
+
+func addUserSource(tx *gorm.DB) error {
+	if err := addColumns(tx, &userV14{}, "Source"); err != nil {
+		return err
+	}
+	return addColumns(tx, &roleBindingV14{}, "Source")
+}
+
+func dropUserSource(tx *gorm.DB) error {
+	if err := dropColumns(tx, &roleBindingV14{}, "Source"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &userV14{}, "Source")
+}
+
+// 0015: 登录锁定的审计日志
+type auditLogV15 struct {
+	gorm.Model
+	Actor  string `gorm:"actor;index"`
+	Action string `gorm:"action;index"`
+	Target string `gorm:"target;index"`
+	Ip     string `gorm:"ip"`
+	Detail string `gorm:"detail"`
+}
+
+func (auditLogV15) TableName() string { return "audit_logs" }
+
+func createAuditLogs(tx *gorm.DB) error {
+	return createTables(tx, &auditLogV15{})
+}
+
+func dropAuditLogs(tx *gorm.DB) error {
+	return dropTables(tx, &auditLogV15{})
+}
+
+// 0016: 审计日志只追加, 去掉软删除相关的列, 增加响应状态并按时间建索引
+type auditLogV16 struct {
+	CreatedAt time.Time `gorm:"index"`
+	Status    int       `gorm:"status"`
+}
+
+func (auditLogV16) TableName() string { return "audit_logs" }
+
+func alterAuditLogs(tx *gorm.DB) error {
+	if err := dropIndexes(tx, &auditLogV15{}, "DeletedAt"); err != nil {
+		return err
+	}
+	if err := dropColumns(tx, &auditLogV15{}, "UpdatedAt", "DeletedAt"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, &auditLogV16{}, "Status"); err != nil {
+		return err
+	}
+	return createIndexes(tx, &auditLogV16{}, "CreatedAt")
+}
+
+func revertAuditLogs(tx *gorm.DB) error {
+	if err := dropIndexes(tx, &auditLogV16{}, "CreatedAt"); err != nil {
+		return err
+	}
+	if err := dropColumns(tx, &auditLogV16{}, "Status"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, &auditLogV15{}, "UpdatedAt", "DeletedAt"); err != nil {
+		return err
+	}
+	return createIndexes(tx, &auditLogV15{}, "DeletedAt")
+}
+
+// 0017: 流水线修订版本
+type taskRevisionV17 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TaskName  string `gorm:"task_name;uniqueIndex:idx_task_revision"`
+	Revision  int    `gorm:"revision;uniqueIndex:idx_task_revision"`
+	Author    string `gorm:"author"`
+	Comment   string `gorm:"comment"`
+	PipeLine  string `gorm:"pipeline;type:text"`
+}
+
+func (taskRevisionV17) TableName() string { return "task_revisions" }
+
+type taskV17 struct {
+	Revision int `gorm:"revision;default:0"`
+}
+
+func (taskV17) TableName() string { return "tasks" }
+
+type buildV17 struct {
+	Revision int `gorm:"revision"`
+}
+
+func (buildV17) TableName() string { return "builds" }
+
+func createTaskRevisions(tx *gorm.DB) error {
+	if err := createTables(tx, &taskRevisionV17{}); err != nil {
+		return err
+	}
+	if err := addColumns(tx, &taskV17{}, "Revision"); err != nil {
+		return err
+	}
+	return addColumns(tx, &buildV17{}, "Revision")
+}
+
+func dropTaskRevisions(tx *gorm.DB) error {
+	if err := dropColumns(tx, &buildV17{}, "Revision"); err != nil {
+		return err
+	}
+	if err := dropColumns(tx, &taskV17{}, "Revision"); err != nil {
+		return err
+	}
+	return dropTables(tx, &taskRevisionV17{})
+}
+
+// 0018: 目录
+type folderV18 struct {
+	gorm.Model
+	Path        string `gorm:"path;uniqueIndex"`
+	Description string `gorm:"description"`
+	Env         string `gorm:"env;type:text"`
+	Labels      string `gorm:"labels"`
+}
+
+func (folderV18) TableName() string { return "folders" }
+
+func createFolders(tx *gorm.DB) error {
+	return createTables(tx, &folderV18{})
+}
+
+func dropFolders(tx *gorm.DB) error {
+	return dropTables(tx, &folderV18{})
+}
+
+// 0019: 关闭时被中断、下次启动需要重新排队的构建
+type buildV19 struct {
+	Requeue bool `gorm:"requeue;default:false"`
+}
+
+func (buildV19) TableName() string { return "builds" }
+
+func addBuildRequeue(tx *gorm.DB) error {
+	return addColumns(tx, &buildV19{}, "Requeue")
+}
+
+func dropBuildRequeue(tx *gorm.DB) error {
+	return dropColumns(tx, &buildV19{}, "Requeue")
+}
+
+// 0020: webhook令牌与API令牌一样只保存哈希
+type taskV20 struct {
+	ID           uint
+	WebhookToken string `gorm:"webhook_token"`
+}
+
+func (taskV20) TableName() string { return "tasks" }
+
+func hashWebhookTokens(tx *gorm.DB) error {
+	var tasks []taskV20
+	if result := tx.Where("webhook_token <> ''").Find(&tasks); result.Error != nil {
+		return result.Error
+	}
+	for _, task := range tasks {
+		if result := tx.Model(&task).Update("webhook_token", HashApiToken(task.WebhookToken)); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// 哈希无法还原, 回滚后需要重新生成webhook令牌
+func unhashWebhookTokens(tx *gorm.DB) error {
+	return tx.Model(&taskV20{}).Where("webhook_token <> ''").Update("webhook_token", "").Error
+}
+
+// 0021: 审批通过后恢复执行的步骤
+type buildV21 struct {
+	ResumeStep int `gorm:"resume_step;default:0"`
+}
+
+func (buildV21) TableName() string { return "builds" }
+
+func addBuildResumeStep(tx *gorm.DB) error {
+	return addColumns(tx, &buildV21{}, "ResumeStep")
+}
+
+func dropBuildResumeStep(tx *gorm.DB) error {
+	return dropColumns(tx, &buildV21{}, "ResumeStep")
+}
+
+// 0022: 未删除的用户名唯一, 角色绑定、令牌和会话都按用户名关联.
+// 已删除的用户保留原名, 所以用部分索引
+type userV22 struct {
+	gorm.Model
+	Name string `gorm:"name"`
+}
+
+func (userV22) TableName() string { return "users" }
+
+func uniqueUserNames(tx *gorm.DB) error {
+	var duplicates []string
+	result := tx.Model(&userV22{}).Group("name").Having("COUNT(*) > 1").Pluck("name", &duplicates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("存在重名的用户, 请先删除或重命名: %s", strings.Join(duplicates, ", "))
+	}
+	return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_name_active ON users (name) WHERE deleted_at IS NULL").Error
+}
+
+func dropUniqueUserNames(tx *gorm.DB) error {
+	return tx.Exec("DROP INDEX IF EXISTS idx_users_name_active").Error
+}
+
+// 0023: 外部身份源中的稳定标识
+type userV23 struct {
+	Subject string `gorm:"subject;index"`
+}
+
+func (userV23) TableName() string { return "users" }
+
+func addUserSubject(tx *gorm.DB) error {
+	if err := addColumns(tx, &userV23{}, "Subject"); err != nil {
+		return err
+	}
+	return createIndexes(tx, &userV23{}, "Subject")
+}
+
+func dropUserSubject(tx *gorm.DB) error {
+	if err := dropIndexes(tx, &userV23{}, "Subject"); err != nil {
+		return err
+	}
+	return dropColumns(tx, &userV23{}, "Subject")
+}
+
+// 0024: 未删除的任务名唯一, 防止并发创建(如多分支扫描)产生同名任务.
+// 与用户名一样, 已删除的任务保留原名, 所以用部分索引
+type taskV24 struct {
+	gorm.Model
+	Name string `gorm:"name"`
+}
+
+func (taskV24) TableName() string { return "tasks" }
+
+func uniqueTaskNames(tx *gorm.DB) error {
+	var duplicates []string
+	result := tx.Model(&taskV24{}).Group("name").Having("COUNT(*) > 1").Pluck("name", &duplicates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("存在重名的任务, 请先删除或重命名: %s", strings.Join(duplicates, ", "))
+	}
+	return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_name_active ON tasks (name) WHERE deleted_at IS NULL").Error
+}
+
+func dropUniqueTaskNames(tx *gorm.DB) error {
+	return tx.Exec("DROP INDEX IF EXISTS idx_tasks_name_active").Error
+}
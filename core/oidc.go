@@ -0,0 +1,354 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 登录请求在IdP停留的最长时间
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrOidcDisabled = errors.New("未启用OIDC登录")
+	ErrOidcState    = errors.New("OIDC登录状态无效或已过期")
+	ErrOidcToken    = errors.New("OIDC身份令牌校验失败")
+	ErrOidcUsername = errors.New("OIDC身份令牌中缺少用户名")
+	ErrOidcSubject  = errors.New("OIDC身份令牌中缺少sub")
+)
+
+// IdP认证的身份. 用户名可能在IdP中被修改或重复使用, Subject(签发者和sub)才是用户的稳定标识
+type OidcIdentity struct {
+	Username string
+	Subject  string
+	Groups   []string
+}
+
+// 外部身份的稳定标识: OIDC的sub只在同一签发者内唯一
+func OidcSubject(issuer, sub string) string {
+	return issuer + "#" + sub
+}
+
+// OIDC单点登录配置, issuer为空时不启用
+type oidcConfig struct {
+	Issuer        string      `yaml:"issuer"`
+	ClientId      string      `yaml:"client_id"`
+	ClientSecret  string      `yaml:"client_secret"`
+	RedirectUrl   string      `yaml:"redirect_url"`
+	Scopes        []string    `yaml:"scopes"`
+	UsernameClaim string      `yaml:"username_claim"`
+	GroupsClaim   string      `yaml:"groups_claim"`
+	GroupRoles    []GroupRole `yaml:"group_roles"`
+	UiRedirect    string      `yaml:"ui_redirect"`
+}
+
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// 发起登录时生成的一次性参数
+type oidcLogin struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var oidc = struct {
+	sync.Mutex
+	provider *oidcProvider
+	keys     map[string]*rsa.PublicKey
+	logins   map[string]oidcLogin
+}{logins: map[string]oidcLogin{}}
+
+func OidcEnabled() bool {
+	return Config.Oidc.Issuer != ""
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// 获取IdP的发现文档, 成功后缓存
+func oidcDiscover(ctx context.Context) (*oidcProvider, error) {
+	oidc.Lock()
+	provider := oidc.provider
+	oidc.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+	provider = &oidcProvider{}
+	discovery := strings.TrimSuffix(Config.Oidc.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := forgeRequest(ctx, http.MethodGet, "", "", discovery, nil, provider); err != nil {
+		return nil, err
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JwksUri == "" {
+		return nil, fmt.Errorf("OIDC发现文档不完整: %s", discovery)
+	}
+	oidc.Lock()
+	oidc.provider = provider
+	oidc.Unlock()
+	return provider, nil
+}
+
+// 生成跳转到IdP的授权地址, 使用PKCE(S256)
+func OidcAuthUrl(ctx context.Context) (string, error) {
+	if !OidcEnabled() {
+		return "", ErrOidcDisabled
+	}
+	provider, err := oidcDiscover(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomString(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	oidc.Lock()
+	now := time.Now()
+	for key, login := range oidc.logins {
+		if login.expires.Before(now) {
+			delete(oidc.logins, key)
+		}
+	}
+	oidc.logins[state] = oidcLogin{verifier: verifier, nonce: nonce, expires: now.Add(oidcStateTTL)}
+	oidc.Unlock()
+
+	scopes := Config.Oidc.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile"}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {Config.Oidc.ClientId},
+		"redirect_uri":          {Config.Oidc.RedirectUrl},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return provider.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// 处理IdP回调: 校验state, 用授权码换取身份令牌并校验, 返回用户名、稳定标识和所属组
+func OidcCallback(ctx context.Context, code, state string) (*OidcIdentity, error) {
+	if !OidcEnabled() {
+		return nil, ErrOidcDisabled
+	}
+	oidc.Lock()
+	login, ok := oidc.logins[state]
+	delete(oidc.logins, state)
+	oidc.Unlock()
+	if !ok || login.expires.Before(time.Now()) {
+		return nil, ErrOidcState
+	}
+	provider, err := oidcDiscover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rawToken, err := oidcExchange(ctx, provider, code, login.verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := verifyIdToken(ctx, provider, rawToken, login.nonce)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrOidcToken
+	}
+	usernameClaim := Config.Oidc.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		return nil, ErrOidcUsername
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, ErrOidcSubject
+	}
+	// 签发者已在verifyIdToken中校验
+	issuer, _ := claims["iss"].(string)
+	groupsClaim := Config.Oidc.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	var groups []string
+	switch v := claims[groupsClaim].(type) {
+	case string:
+		groups = append(groups, v)
+	case []any:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return &OidcIdentity{Username: username, Subject: OidcSubject(issuer, sub), Groups: groups}, nil
+}
+
+func oidcExchange(ctx context.Context, provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {Config.Oidc.RedirectUrl},
+		"client_id":     {Config.Oidc.ClientId},
+		"code_verifier": {verifier},
+	}
+	if Config.Oidc.ClientSecret != "" {
+		form.Set("client_secret", Config.Oidc.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("POST %s: %s", provider.TokenEndpoint, resp.Status)
+	}
+	var body struct {
+		IdToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.IdToken == "" {
+		return "", ErrOidcToken
+	}
+	return body.IdToken, nil
+}
+
+// 校验身份令牌的签名(RS256)、签发者、受众、有效期和nonce
+func verifyIdToken(ctx context.Context, provider *oidcProvider, raw, nonce string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return oidcKey(ctx, provider, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrOidcToken
+	}
+	if !claims.VerifyIssuer(Config.Oidc.Issuer, true) {
+		return nil, errors.New("身份令牌签发者不匹配")
+	}
+	if !claims.VerifyAudience(Config.Oidc.ClientId, true) {
+		return nil, errors.New("身份令牌受众不匹配")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("身份令牌已过期")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("身份令牌nonce不匹配")
+	}
+	return claims, nil
+}
+
+// 按kid查找签名公钥, 未命中时重新拉取JWKS以支持IdP轮换密钥
+func oidcKey(ctx context.Context, provider *oidcProvider, kid string) (*rsa.PublicKey, error) {
+	oidc.Lock()
+	key, ok := oidc.keys[kid]
+	oidc.Unlock()
+	if ok {
+		return key, nil
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := forgeRequest(ctx, http.MethodGet, "", "", provider.JwksUri, nil, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := rsaKey(k)
+		if err != nil {
+			slog.Error(err.Error())
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	oidc.Lock()
+	oidc.keys = keys
+	oidc.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// 只有一个密钥且令牌未指定kid时直接使用
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到签名公钥: %s", kid)
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31 {
+		return nil, fmt.Errorf("无效的RSA公钥指数: %s", k.Kid)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
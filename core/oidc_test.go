@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 模拟的IdP: 授权时记录PKCE challenge和nonce, 换取令牌时校验code_verifier
+type mockIdp struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]url.Values
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdp{t: t, key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{{
+			Kid: "k1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	oldConfig := Config.Oidc
+	Config.Oidc = oidcConfig{Issuer: idp.server.URL, ClientId: "gookins", RedirectUrl: "http://gookins/oidc/callback"}
+	resetOidc()
+	t.Cleanup(func() {
+		Config.Oidc = oldConfig
+		resetOidc()
+	})
+	return idp
+}
+
+func resetOidc() {
+	oidc.Lock()
+	oidc.provider, oidc.keys, oidc.logins = nil, nil, map[string]oidcLogin{}
+	oidc.Unlock()
+}
+
+// 模拟用户在IdP登录: 校验授权请求并签发授权码
+func (idp *mockIdp) authorize(authUrl string) (code, state string) {
+	idp.t.Helper()
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		idp.t.Fatalf("授权请求应使用PKCE S256: %s", authUrl)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		idp.t.Fatalf("授权请求应包含state和nonce: %s", authUrl)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code = "code-" + query.Get("state")
+	idp.codes[code] = query
+	return code, query.Get("state")
+}
+
+func (idp *mockIdp) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	idp.mu.Lock()
+	auth, ok := idp.codes[r.Form.Get("code")]
+	delete(idp.codes, r.Form.Get("code"))
+	idp.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.Get("code_challenge") || r.Form.Get("redirect_uri") != auth.Get("redirect_uri") {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                "gookins",
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             []string{"devs"},
+		"nonce":              auth.Get("nonce"),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+func TestOidcLogin(t *testing.T) {
+	idp := newMockIdp(t)
+	ctx := context.Background()
+	authUrl, err := OidcAuthUrl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.authorize(authUrl)
+	identity, err := OidcCallback(ctx, code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != "alice" || identity.Subject != OidcSubject(idp.server.URL, "user-1") {
+		t.Errorf("身份应包含用户名和签发者+sub, 实际为%+v", identity)
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "devs" {
+		t.Errorf("身份应包含所属组, 实际为%v", identity.Groups)
+	}
+	// state只能使用一次
+	if _, err := OidcCallback(ctx, code, state); !errors.Is(err, ErrOidcState) {
+		t.Errorf("重复使用state应被拒绝, 实际为%v", err)
+	}
+}
+
+func TestOidcCallbackRejectsUnknownState(t *testing.T) {
+	idp := newMockIdp(t)
+	ctx := context.Background()
+	authUrl, err := OidcAuthUrl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := idp.authorize(authUrl)
+	if _, err := OidcCallback(ctx, code, "forged"); !errors.Is(err, ErrOidcState) {
+		t.Errorf("未知的state应被拒绝, 实际为%v", err)
+	}
+}
+
+func TestOidcCallbackBindsCodeToLogin(t *testing.T) {
+	idp := newMockIdp(t)
+	ctx := context.Background()
+	first, err := OidcAuthUrl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OidcAuthUrl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, firstState := idp.authorize(first)
+	secondCode, _ := idp.authorize(second)
+	// 另一次登录的授权码与本次的code_verifier不匹配
+	if _, err := OidcCallback(ctx, secondCode, firstState); err == nil {
+		t.Error("授权码与登录状态不匹配时应被拒绝")
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("密码长度不足")
+	ErrPasswordBreached = errors.New("密码出现在已泄露密码列表中")
+
+	breachedOnce      sync.Once
+	breachedPasswords map[string]bool
+)
+
+// argon2id参数, 未配置时使用OWASP推荐的默认值
+func argon2Params() (time, memory uint32, threads uint8) {
+	time, memory, threads = 2, 19*1024, 1
+	if Config.HashTime > 0 {
+		time = Config.HashTime
+	}
+	if Config.HashMemory > 0 {
+		memory = Config.HashMemory
+	}
+	if Config.HashThreads > 0 {
+		threads = Config.HashThreads
+	}
+	return
+}
+
+// 哈希用户密码: 每个用户随机盐的argon2id, 编码为PHC字符串格式
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	time, memory, threads := argon2Params()
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// 旧版本使用全局SaltKey的HMAC-SHA256哈希, 仅用于验证和迁移
+func legacyHashPassword(password string) string {
+	h := hmac.New(sha256.New, []byte(Config.SaltKey))
+	h.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+type argon2Hash struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2Hash(encoded string) (*argon2Hash, bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, false
+	}
+	var h argon2Hash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return nil, false
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, false
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, false
+	}
+	return &h, true
+}
+
+// 验证用户密码, 同时支持argon2id和旧版HMAC哈希
+func VerifyPassword(hashPasswd, password string) bool {
+	if h, ok := parseArgon2Hash(hashPasswd); ok {
+		key := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+		return subtle.ConstantTimeCompare(key, h.key) == 1
+	}
+	return hmac.Equal([]byte(hashPasswd), []byte(legacyHashPassword(password)))
+}
+
+// 旧版哈希或参数与当前配置不一致时需要在登录成功后重新哈希
+func NeedsRehash(hashPasswd string) bool {
+	h, ok := parseArgon2Hash(hashPasswd)
+	if !ok {
+		return true
+	}
+	time, memory, threads := argon2Params()
+	return h.time != time || h.memory != memory || h.threads != threads
+}
+
+// 加载泄露密码列表, 每行一个明文密码或SHA-1(十六进制, 可带":次数"后缀)
+func loadBreachedPasswords() {
+	breachedPasswords = make(map[string]bool)
+	if Config.PasswordBreachedFile == "" {
+		return
+	}
+	file, err := os.Open(Config.PasswordBreachedFile)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash, _, ok := strings.Cut(line, ":"); ok && len(hash) == 40 {
+			line = hash
+		}
+		breachedPasswords[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// 密码策略检查: 最小长度和泄露密码列表
+func CheckPasswordPolicy(password string) error {
+	minLength := Config.PasswordMinLength
+	if minLength <= 0 {
+		minLength = 8
+	}
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("%w: 至少%d个字符", ErrPasswordTooShort, minLength)
+	}
+	breachedOnce.Do(loadBreachedPasswords)
+	sum := sha1.Sum([]byte(password))
+	if breachedPasswords[strings.ToLower(password)] || breachedPasswords[hex.EncodeToString(sum[:])] {
+		return ErrPasswordBreached
+	}
+	return nil
+}
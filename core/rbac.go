@@ -0,0 +1,136 @@
+package core
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gookins/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PermView    = "view"
+	PermRun     = "run"
+	PermEdit    = "edit"
+	PermCancel  = "cancel"
+	PermApprove = "approve"
+	// 管理用户、角色、凭据和任务池
+	PermAdmin = "admin"
+)
+
+var (
+	ErrPermissionDenied = errors.New("没有权限执行该操作")
+
+	rolePermissions = map[string][]string{
+		model.RoleAdmin:      {PermView, PermRun, PermEdit, PermCancel, PermApprove, PermAdmin},
+		model.RoleMaintainer: {PermView, PermRun, PermEdit, PermCancel, PermApprove},
+		model.RoleDeveloper:  {PermView, PermRun, PermCancel},
+		model.RoleViewer:     {PermView},
+	}
+)
+
+// 角色绑定的范围是否覆盖任务name
+func scopeMatches(scope, name string) bool {
+	if scope == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(scope, "*"); ok {
+		return name != "" && strings.HasPrefix(name, prefix)
+	}
+	return scope == name
+}
+
+// 用户在任务name上是否拥有权限perm, name为空时只考虑全局角色
+func HasPermission(username, perm, name string) bool {
+	return permissionChecker(username, perm)(name)
+}
+
+// 一次读取用户的角色绑定, 返回检查任务权限的函数.
+// 用于逐个检查大量任务, 避免每个任务查询一次角色绑定
+func permissionChecker(username, perm string) func(name string) bool {
+	if username == "" {
+		return func(string) bool { return false }
+	}
+	var bindings []model.RoleBinding
+	if result := Db.Where("user_name = ?", username).Find(&bindings); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return func(string) bool { return false }
+	}
+	return func(name string) bool {
+		for _, binding := range bindings {
+			if !scopeMatches(binding.Scope, name) {
+				continue
+			}
+			for _, p := range rolePermissions[binding.Role] {
+				if p == perm {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// 当前请求的用户是否拥有权限, 同时受API令牌范围限制
+func Can(ctx *gin.Context, perm, name string) bool {
+	return Checker(ctx, perm)(name)
+}
+
+// 与Can相同, 但只读取一次角色绑定
+func Checker(ctx *gin.Context, perm string) func(name string) bool {
+	if !scopeAllows(ctx.GetString(TokenScopeKey), perm) {
+		return func(string) bool { return false }
+	}
+	return permissionChecker(ctx.GetString(UsernameKey), perm)
+}
+
+// 拒绝限定范围的API令牌, 用于令牌管理等只允许完整权限的接口
+func FullScopeMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString(TokenScopeKey) != "" {
+			ctx.JSON(http.StatusForbidden, model.ApiRespone{Code: http.StatusForbidden, Message: ErrPermissionDenied.Error()})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 权限检查中间件, scope从请求中解析出任务名, 为nil时检查全局权限
+func PermissionMiddleware(perm string, scope func(*gin.Context) string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ""
+		if scope != nil {
+			name = scope(ctx)
+		}
+		if !Can(ctx, perm, name) {
+			ctx.JSON(http.StatusForbidden, model.ApiRespone{Code: http.StatusForbidden, Message: ErrPermissionDenied.Error()})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 没有任何角色绑定时将最早创建的本地用户设为管理员.
+// 外部身份源的用户由组映射授权, 不能因为首先登录而成为管理员
+func BootstrapAdmin() {
+	var count int64
+	if result := Db.Model(&model.RoleBinding{}).Count(&count); result.Error != nil || count > 0 {
+		return
+	}
+	var user model.User
+	result := Db.Where("source = ?", model.UserSourceLocal).Order("id").Limit(1).Find(&user)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	binding := model.RoleBinding{UserName: user.Name, Role: model.RoleAdmin}
+	if result := Db.Create(&binding); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	slog.Info("初始化管理员: " + user.Name)
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 工作目录根下的目录
+func WorkspaceDir(name string) string {
+	root := Config.WorkSpace
+	if root == "" {
+		root = "workspace"
+	}
+	return filepath.Join(root, name)
+}
+
+// 构建的工作目录, 按构建ID区分, 同一任务的并发构建互不影响
+func BuildWorkspaceDir(id uint64) string {
+	return WorkspaceDir(filepath.Join("builds", strconv.FormatUint(id, 10)))
+}
+
+// 构建结束后删除工作目录
+func RemoveBuildWorkspace(id uint64) {
+	if err := os.RemoveAll(BuildWorkspaceDir(id)); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// 为http(s)仓库地址附加代码仓库认证信息
+func authRepoURL(repo string) string {
+	if Config.CodeUser == "" {
+		return repo
+	}
+	u, err := url.Parse(repo)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return repo
+	}
+	u.User = url.UserPassword(Config.CodeUser, Config.CodePass)
+	return u.String()
+}
+
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w, output: %s", args[0], err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// 检出仓库的指定提交到dir, commit为空时检出引用ref的最新提交, 返回检出的提交
+func checkout(ctx context.Context, repo, ref, commit, dir string) (string, error) {
+	if commit != "" {
+		ref = commit
+	} else if ref == "" {
+		ref = "HEAD"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if _, err := runGit(ctx, dir, "init", "-q"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := runGit(ctx, dir, "fetch", "-q", "--depth", "1", authRepoURL(repo), ref); err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, dir, "checkout", "-q", "-f", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return runGit(ctx, dir, "rev-parse", "HEAD")
+}
+
+// 远程仓库中引用当前指向的提交. ref可以是完整引用名, 也可以是分支名或标签名, 为空时为默认分支
+func ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	output, err := runGit(ctx, "", "ls-remote", authRepoURL(repo), ref)
+	if err != nil {
+		return "", err
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	// 附注标签取其指向的提交
+	for _, name := range []string{ref, "refs/heads/" + ref, "refs/tags/" + ref + "^{}", "refs/tags/" + ref} {
+		if sha, ok := refs[name]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("引用%s不存在", ref)
+}
+
+// 列出远程仓库的分支及其提交
+func ListBranches(ctx context.Context, repo string) (map[string]string, error) {
+	output, err := runGit(ctx, "", "ls-remote", "--heads", authRepoURL(repo))
+	if err != nil {
+		return nil, err
+	}
+	branches := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		branches[strings.TrimPrefix(fields[1], "refs/heads/")] = fields[0]
+	}
+	return branches, nil
+}
+
+// 检查远程仓库的指定引用中是否存在流水线文件, dir为用于抓取的本地缓存仓库
+func HasPipelineFile(ctx context.Context, dir, repo, ref, path string) (bool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); os.IsNotExist(err) {
+		if _, err := runGit(ctx, dir, "init", "-q", "--bare"); err != nil {
+			return false, err
+		}
+	}
+	if _, err := runGit(ctx, dir, "fetch", "-q", "--depth", "1", authRepoURL(repo), ref); err != nil {
+		return false, err
+	}
+	_, err := runGit(ctx, dir, "cat-file", "-e", "FETCH_HEAD:"+path)
+	return err == nil, nil
+}
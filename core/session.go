@@ -0,0 +1,126 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gookins/model"
+)
+
+// 刷新令牌的前缀
+const refreshTokenPrefix = "gkr_"
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return refreshTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+func refreshExpiry() time.Time {
+	return time.Now().Add(time.Minute * time.Duration(Config.RefreshExpiredTime))
+}
+
+// 创建登录会话, 返回访问token和刷新令牌
+func CreateSession(username string) (string, string, error) {
+	refresh, err := newRefreshToken()
+	if err != nil {
+		slog.Error(err.Error())
+		return "", "", ErrCreateToken
+	}
+	session := model.Session{UserName: username, RefreshHash: HashApiToken(refresh), ExpiresAt: refreshExpiry()}
+	if result := Db.Create(&session); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return "", "", ErrCreateToken
+	}
+	access, err := CreateToken(username, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// 用刷新令牌换取新的访问token和刷新令牌, 旧刷新令牌随之失效.
+// 已轮换的旧刷新令牌再次出现说明可能被盗用, 吊销整个会话
+func RefreshSession(refresh string) (string, string, error) {
+	hash := HashApiToken(refresh)
+	var session model.Session
+	result := Db.Where("refresh_hash = ?", hash).Limit(1).Find(&session)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return "", "", ErrRefreshToken
+	}
+	if result.RowsAffected == 0 {
+		if result := Db.Where("prev_hash = ?", hash).Delete(&model.Session{}); result.Error == nil && result.RowsAffected > 0 {
+			slog.Warn("检测到已轮换的刷新令牌被重复使用, 吊销会话")
+		}
+		return "", "", ErrRefreshToken
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		return "", "", ErrRefreshToken
+	}
+	if err := checkUser(session.UserName); err != nil {
+		return "", "", err
+	}
+	next, err := newRefreshToken()
+	if err != nil {
+		slog.Error(err.Error())
+		return "", "", ErrCreateToken
+	}
+	// 以当前哈希为条件更新, 并发刷新时只有一个成功
+	result = Db.Model(&session).Where("refresh_hash = ?", hash).Updates(map[string]any{
+		"refresh_hash": HashApiToken(next),
+		"prev_hash":    hash,
+		"expires_at":   refreshExpiry(),
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return "", "", ErrRefreshToken
+	}
+	access, err := CreateToken(session.UserName, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return access, next, nil
+}
+
+// 吊销单个会话(登出)
+func RevokeSession(sid uint) error {
+	if result := Db.Where("id = ?", sid).Delete(&model.Session{}); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// 吊销用户的所有会话, 用于修改密码、禁用或删除用户
+func RevokeUserSessions(username string) {
+	result := Db.Where("user_name = ?", username).Delete(&model.Session{})
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	slog.Info(fmt.Sprintf("吊销用户%s的%d个会话", username, result.RowsAffected))
+}
+
+func checkSession(sid uint, username string) error {
+	var count int64
+	result := Db.Model(&model.Session{}).Where("id = ? AND user_name = ?", sid, username).Count(&count)
+	if result.Error != nil || count == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// 用户存在且未被禁用
+func checkUser(username string) error {
+	var user model.User
+	result := Db.Select("id, disabled").Where("name = ?", username).Limit(1).Find(&user)
+	if result.Error != nil || result.RowsAffected == 0 || user.Disabled {
+		return ErrUserInactive
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gookins/model"
+)
+
+// 构建状态到平台提交状态的映射
+var (
+	githubStates = map[string]string{
+		TaskPending:     "pending",
+		TaskRunning:     "pending",
+		TaskCompleted:   "success",
+		TaskFailure:     "failure",
+		TaskCancelled:   "error",
+		TaskInterrupted: "error",
+		TaskWaiting:     "pending",
+		TaskRejected:    "failure",
+	}
+	gitlabStates = map[string]string{
+		TaskPending:     "pending",
+		TaskRunning:     "running",
+		TaskCompleted:   "success",
+		TaskFailure:     "failed",
+		TaskCancelled:   "canceled",
+		TaskInterrupted: "canceled",
+		TaskWaiting:     "pending",
+		TaskRejected:    "failed",
+	}
+)
+
+// 查找任务的代码托管平台配置, 多分支子任务使用父任务的配置
+func taskForge(name string) (*model.Task, bool) {
+	var task model.Task
+	result := Db.Where("name = ?", name).Limit(1).Find(&task)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, false
+	}
+	if task.Kind == model.TaskKindBranch && task.ParentId != 0 {
+		var parent model.Task
+		result := Db.Where("id = ?", task.ParentId).Limit(1).Find(&parent)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, false
+		}
+		return &parent, parent.Forge != ""
+	}
+	return &task, task.Forge != ""
+}
+
+// 构建状态变化时将提交状态回报给代码托管平台
+func reportStatus(id uint64, task *TaskJob, state string) {
+	if task.Commit == "" {
+		return
+	}
+	forge, ok := taskForge(task.Name)
+	if !ok || forge.ForgeRepo == "" {
+		return
+	}
+	states := githubStates
+	if forge.Forge == ForgeGitlab {
+		states = gitlabStates
+	}
+	forgeState, ok := states[state]
+	if !ok {
+		return
+	}
+	targetURL := fmt.Sprintf("%s/task?build=%d", strings.TrimSuffix(Config.ExternalUrl, "/"), id)
+	description := fmt.Sprintf("build #%d %s", id, state)
+	commit := task.Commit
+	statusPosts.push(id, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := PostCommitStatus(ctx, forge.Name, forge.Forge, forge.ForgeApi, forge.ForgeRepo, commit, forgeState, targetURL, description, "gookins/"+task.Name)
+		if err != nil {
+			slog.Error(fmt.Sprintf("report status for build %d: %v", id, err))
+		}
+	})
+}
+
+// 提交状态按构建排队发送: 同一构建的状态依次发送, 后发生的状态不会被先发生的覆盖
+type statusQueue struct {
+	mu    sync.Mutex
+	posts map[uint64][]func()
+}
+
+var statusPosts = &statusQueue{posts: make(map[uint64][]func())}
+
+func (q *statusQueue) push(id uint64, post func()) {
+	q.mu.Lock()
+	queue, sending := q.posts[id]
+	q.posts[id] = append(queue, post)
+	q.mu.Unlock()
+	if !sending {
+		go q.send(id)
+	}
+}
+
+// 依次发送构建的状态, 发送完后退出, 之后的状态由push重新启动
+func (q *statusQueue) send(id uint64) {
+	for {
+		q.mu.Lock()
+		queue := q.posts[id]
+		if len(queue) == 0 {
+			delete(q.posts, id)
+			q.mu.Unlock()
+			return
+		}
+		q.posts[id] = queue[1:]
+		q.mu.Unlock()
+		queue[0]()
+	}
+}
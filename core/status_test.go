@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gookins/model"
+)
+
+type postedStatus struct {
+	sha   string
+	state string
+}
+
+// 模拟gitlab的提交状态接口, 第一个请求较慢
+func statusServer(t *testing.T) (*httptest.Server, func(n int) []postedStatus) {
+	t.Helper()
+	var mu sync.Mutex
+	var posted []postedStatus
+	first := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		slow := first
+		first = false
+		mu.Unlock()
+		if slow {
+			time.Sleep(100 * time.Millisecond)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		posted = append(posted, postedStatus{sha: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], state: body["state"]})
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	wait := func(n int) []postedStatus {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			if len(posted) >= n {
+				defer mu.Unlock()
+				return append([]postedStatus(nil), posted...)
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("没有收到%d个提交状态", n)
+		return nil
+	}
+	return server, wait
+}
+
+func TestReportStatusInOrder(t *testing.T) {
+	openTestDb(t)
+	server, wait := statusServer(t)
+	if err := Db.Create(&model.Task{Name: "app", Forge: ForgeGitlab, ForgeApi: server.URL, ForgeRepo: "o/r"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	task := &TaskJob{Name: "app", Commit: "abc"}
+	for _, state := range []string{TaskPending, TaskRunning, TaskCompleted} {
+		reportStatus(1, task, state)
+	}
+	posted := wait(3)
+	want := []string{"pending", "running", "success"}
+	for i, status := range posted {
+		if status.state != want[i] || status.sha != "abc" {
+			t.Fatalf("提交状态为%+v", posted)
+		}
+	}
+}
+
+// 仓库任务排队时确定提交, pending状态回报到该提交
+func TestAddTaskPinsCommit(t *testing.T) {
+	openTestDb(t)
+	server, wait := statusServer(t)
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "init")
+	sha := git("rev-parse", "HEAD")
+	if err := Db.Create(&model.Task{Name: "app", Repo: "file://" + repo, Forge: ForgeGitlab, ForgeApi: server.URL, ForgeRepo: "o/r"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	tp := NewTaskPool(context.Background())
+	tp.capacity = 1
+	id, err := tp.AddTask(&TaskJob{Name: "app", Repo: "file://" + repo, Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var build model.Build
+	Db.Where("id = ?", id).Find(&build)
+	if build.Commit != sha {
+		t.Fatalf("构建的提交为%q, 应为%q", build.Commit, sha)
+	}
+	if posted := wait(1); posted[0].sha != sha || posted[0].state != "pending" {
+		t.Fatalf("提交状态为%+v", posted)
+	}
+}
@@ -1 +1,255 @@
-package core
\ No newline at end of file
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 仓库中流水线文件的默认路径
+const DefaultPipelinePath = ".gookins.yml"
+
+var (
+	ErrPipelineEmpty = errors.New("流水线没有任何步骤")
+	ErrInvalidParam  = errors.New("构建参数名无效")
+)
+
+// 构建参数作为环境变量传给步骤, 名称只能是普通的环境变量名
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 构建自身设置的变量, 以及会改变shell或动态链接行为的变量, 不能由构建参数覆盖.
+// 另外LD_开头的变量和服务进程环境中已有的变量也不能覆盖
+var reservedParams = map[string]bool{
+	"PATH":           true,
+	"BASH_ENV":       true,
+	"ENV":            true,
+	"IFS":            true,
+	"SHELLOPTS":      true,
+	"BASHOPTS":       true,
+	"PS4":            true,
+	"PROMPT_COMMAND": true,
+	"BRANCH_NAME":    true,
+	"CHANGE_ID":      true,
+	"NODE_LABELS":    true,
+}
+
+// 校验构建参数名, 手动运行、上下游触发、webhook触发和执行前都要校验
+func ValidateParams(params map[string]string) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !paramNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidParam, name)
+		}
+		if reservedParams[name] || strings.HasPrefix(name, "LD_") {
+			return fmt.Errorf("%w: %s是保留的环境变量", ErrInvalidParam, name)
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			return fmt.Errorf("%w: %s与服务进程的环境变量重名", ErrInvalidParam, name)
+		}
+	}
+	return nil
+}
+
+type pipeLine struct {
+	Name  string `yaml:"name"`
+	Steps []step `yaml:"steps"`
+}
+
+type step struct {
+	Name        string              `yaml:"name"`
+	Command     string              `yaml:"command"`
+	Trigger     *triggerStep        `yaml:"trigger"`
+	Approval    *approvalStep       `yaml:"approval"`
+	Credentials []credentialBinding `yaml:"credentials"`
+}
+
+// 步骤引用的凭据及其注入方式: env用于secret, username_env/password_env用于username_password,
+// file_env为ssh_key和file写入的临时文件路径
+type credentialBinding struct {
+	Id          string `yaml:"id"`
+	Env         string `yaml:"env"`
+	UsernameEnv string `yaml:"username_env"`
+	PasswordEnv string `yaml:"password_env"`
+	FileEnv     string `yaml:"file_env"`
+}
+
+// 人工审批: approvers为空时任何用户都可以审批, timeout单位为分钟, 0表示不超时
+type approvalStep struct {
+	Message   string   `yaml:"message"`
+	Approvers []string `yaml:"approvers"`
+	Timeout   int      `yaml:"timeout"`
+}
+
+// 触发另一个任务, wait为true时等待其结果
+type triggerStep struct {
+	Task   string            `yaml:"task"`
+	Params map[string]string `yaml:"params"`
+	Wait   bool              `yaml:"wait"`
+}
+
+// 解析并校验流水线
+func parsePipeline(data string) (*pipeLine, error) {
+	var pipeline pipeLine
+	if err := yaml.Unmarshal([]byte(data), &pipeline); err != nil {
+		return nil, fmt.Errorf("解析流水线失败: %w", err)
+	}
+	if len(pipeline.Steps) == 0 {
+		return nil, ErrPipelineEmpty
+	}
+	for i, step := range pipeline.Steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("第%d个步骤缺少name", i+1)
+		}
+		if step.Approval != nil {
+			continue
+		}
+		if step.Trigger != nil {
+			if step.Trigger.Task == "" {
+				return nil, fmt.Errorf("步骤%s的trigger缺少task", step.Name)
+			}
+			continue
+		}
+		if step.Command == "" {
+			return nil, fmt.Errorf("步骤%s缺少command", step.Name)
+		}
+		for _, binding := range step.Credentials {
+			if binding.Id == "" {
+				return nil, fmt.Errorf("步骤%s引用的凭据缺少id", step.Name)
+			}
+		}
+	}
+	return &pipeline, nil
+}
+
+// 校验流水线内容, 供创建和更新任务时使用
+func ValidatePipeline(data string) error {
+	_, err := parsePipeline(data)
+	return err
+}
+
+// 流水线trigger步骤触发的任务, 去重并排序. 流水线无法解析时为空
+func PipelineTriggers(data string) []string {
+	pipeline, err := parsePipeline(data)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, step := range pipeline.Steps {
+		if step.Trigger != nil && !seen[step.Trigger.Task] {
+			seen[step.Trigger.Task] = true
+			names = append(names, step.Trigger.Task)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 流水线引用的凭据id, 去重并排序. 流水线无法解析时为空
+func PipelineCredentials(data string) []string {
+	pipeline, err := parsePipeline(data)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var ids []string
+	for _, step := range pipeline.Steps {
+		for _, binding := range step.Credentials {
+			if !seen[binding.Id] {
+				seen[binding.Id] = true
+				ids = append(ids, binding.Id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 将流水线trigger步骤中的目标任务old改为new, prefix时同时改写old/下的任务.
+// 按解析出的位置只替换任务名本身, 保留流水线原有的格式和注释; 流水线无法解析时原样返回
+func RenameTriggerTargets(data, old, new string, prefix bool) (string, bool) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &root); err != nil || len(root.Content) == 0 {
+		return data, false
+	}
+	var targets []*yaml.Node
+	for _, item := range mappingValue(root.Content[0], "steps").Content {
+		if task := mappingValue(mappingValue(item, "trigger"), "task"); task.Kind == yaml.ScalarNode {
+			targets = append(targets, task)
+		}
+	}
+	// 同一行可能有多个目标(流式写法), 从行尾往前替换以免位置偏移
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Line != targets[j].Line {
+			return targets[i].Line < targets[j].Line
+		}
+		return targets[i].Column > targets[j].Column
+	})
+	lines := strings.SplitAfter(data, "\n")
+	changed := false
+	for _, target := range targets {
+		var renamed string
+		switch {
+		case target.Value == old:
+			renamed = new
+		case prefix && strings.HasPrefix(target.Value, old+"/"):
+			renamed = new + strings.TrimPrefix(target.Value, old)
+		default:
+			continue
+		}
+		// 行号和列号按字符计数, 从1开始
+		line := []rune(lines[target.Line-1])
+		start := target.Column - 1
+		raw := []rune(quoteScalar(target.Value, target.Style))
+		end := start + len(raw)
+		// 带转义或跨行的写法无法按原样定位, 不改写
+		if start < 0 || end > len(line) || string(line[start:end]) != string(raw) {
+			continue
+		}
+		lines[target.Line-1] = string(line[:start]) + quoteScalar(renamed, target.Style) + string(line[end:])
+		changed = true
+	}
+	if !changed {
+		return data, false
+	}
+	renamed := strings.Join(lines, "")
+	if _, err := parsePipeline(renamed); err != nil {
+		return data, false
+	}
+	return renamed, true
+}
+
+// 映射节点中键对应的值, 不存在时为空节点
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	}
+	return &yaml.Node{}
+}
+
+// 按原来的引号风格写出字符串, 不加引号会改变含义时改用双引号
+func quoteScalar(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
+	case value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, ":#,[]{}&*!|>'\"%@`"):
+		return strconv.Quote(value)
+	}
+	return value
+}
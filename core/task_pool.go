@@ -1,22 +1,28 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"gookins/model"
-
-	"gopkg.in/yaml.v3"
 )
 
 type TaskJob model.TaskForm
 
 var (
-	ErrTaskPoolFull = errors.New("task pool is full")
+	ErrTaskPoolFull     = errors.New("task pool is full")
+	ErrTaskPoolDraining = errors.New("task pool is draining")
+	ErrWorkerCount      = errors.New("worker count must be positive")
 )
 
 const (
@@ -24,15 +30,49 @@ const (
 	StrategyDrop   = "drop"
 	StrategyExpand = "expand"
 
-	TaskPending   = "pending"
-	TaskRunning   = "running"
-	TaskFailure   = "failure"
-	TaskCancelled = "cancelled"
-	TaskCompleted = "completed"
+	TaskPending     = "pending"
+	TaskRunning     = "running"
+	TaskFailure     = "failure"
+	TaskCancelled   = "cancelled"
+	TaskCompleted   = "completed"
+	TaskInterrupted = "interrupted"
+	TaskWaiting     = "waiting"
+	TaskRejected    = "rejected"
 )
 
+// 构建是否已经结束
+func finishedState(state string) bool {
+	switch state {
+	case TaskFailure, TaskCancelled, TaskCompleted, TaskInterrupted, TaskRejected:
+		return true
+	}
+	return false
+}
+
+// 排队中的任务
+type queuedJob struct {
+	id       uint64
+	job      *TaskJob
+	queuedAt time.Time
+}
+
+// 正在执行的任务
+type runningJob struct {
+	id        uint64
+	job       *TaskJob
+	startedAt time.Time
+}
+
 type TaskPool struct {
-	queue       chan *TaskJob
+	queue       []*queuedJob
+	reserved    int
+	waiting     int
+	running     map[int]*runningJob
+	capacity    int
+	workers     int
+	alive       map[int]bool
+	paused      bool
+	draining    bool
 	wg          sync.WaitGroup
 	cancelFuncs sync.Map
 	states      sync.Map
@@ -40,97 +80,487 @@ type TaskPool struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	mu          sync.Mutex
+	stateMu     sync.Mutex
+	notEmpty    *sync.Cond
+	notFull     *sync.Cond
 }
 
 func NewTaskPool(ctx context.Context) *TaskPool {
 	ctx, cancel := context.WithCancel(ctx)
-	return &TaskPool{
-		queue:    make(chan *TaskJob, Config.TaskPoolSize),
+	tp := &TaskPool{
+		running:  make(map[int]*runningJob),
+		alive:    make(map[int]bool),
+		workers:  Config.WorkerCount,
+		capacity: Config.TaskPoolSize,
 		strategy: Config.Strategy,
 		ctx:      ctx,
 		cancel:   cancel,
 	}
+	tp.notEmpty = sync.NewCond(&tp.mu)
+	tp.notFull = sync.NewCond(&tp.mu)
+	return tp
 }
 
-func (tp *TaskPool) AddTask(task *TaskJob) error {
-	tp.states.Store(task.Name, TaskPending)
+// 添加任务到任务池, 返回构建ID
+func (tp *TaskPool) AddTask(task *TaskJob) (uint64, error) {
+	return tp.addTask(task, true)
+}
 
-	switch tp.strategy {
-	case StrategyBlock:
-		tp.queue <- task
-		slog.Info(fmt.Sprintf("Task: %s is added to the pool", task.Name))
-	case StrategyDrop:
-		select {
-		case tp.queue <- task:
-			slog.Info(fmt.Sprintf("Task %s added to the pool", task.Name))
-		default:
+// wait为false时队列满也不等待: worker中触发的构建若等待其他worker腾出队列位置,
+// 所有worker都这样等待时会死锁, 所以阻塞策略下直接超出容量排队
+func (tp *TaskPool) addTask(task *TaskJob, wait bool) (uint64, error) {
+	if err := tp.admit(task, wait); err != nil {
+		return 0, err
+	}
+	pinCommit(task)
+	params, _ := json.Marshal(task.Params)
+	causes, _ := json.Marshal(task.Causes)
+	build := model.Build{
+		TaskName: task.Name,
+		PipeLine: task.PipeLine,
+		Repo:     task.Repo,
+		Ref:      task.Ref,
+		Branch:   task.Branch,
+		ChangeId: task.ChangeId,
+		Commit:   task.Commit,
+		Params:   string(params),
+		Causes:   string(causes),
+		State:    TaskPending,
+		Revision: task.Revision,
+	}
+	// 写构建记录和上报状态不持有tp.mu, 避免数据库或代码托管平台变慢时阻塞整个任务池
+	if result := Db.Create(&build); result.Error != nil {
+		slog.Error(result.Error.Error())
+		tp.release()
+		return 0, ErrCreateBuild
+	}
+	id := uint64(build.ID)
+	reportStatus(id, task, TaskPending)
+	if err := tp.enqueueReserved(id, task); err != nil {
+		return 0, err
+	}
+	slog.Info(fmt.Sprintf("Task %s added to the pool, build: %d", task.Name, build.ID))
+	return id, nil
+}
+
+// 将已经通过admit的构建加入队列, 使用预留的队列位置
+func (tp *TaskPool) enqueueReserved(id uint64, task *TaskJob) error {
+	tp.mu.Lock()
+	tp.reserved--
+	if tp.ctx.Err() != nil {
+		// 写记录期间任务池已经停止, 关闭流程不会再处理这个构建
+		tp.mu.Unlock()
+		tp.setState(id, task, TaskInterrupted)
+		return ErrTaskPoolDraining
+	}
+	tp.enqueue(id, task)
+	tp.mu.Unlock()
+	return nil
+}
+
+// 按排空状态和队列容量决定是否接收任务, 接收时预留一个队列位置, 由enqueue前或失败时归还
+func (tp *TaskPool) admit(task *TaskJob, wait bool) error {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.draining {
+		slog.Info(fmt.Sprintf("Task pool is draining, task %s rejected", task.Name))
+		return ErrTaskPoolDraining
+	}
+	if len(tp.queue)+tp.reserved >= tp.capacity {
+		switch tp.strategy {
+		case StrategyDrop:
 			slog.Info(fmt.Sprintf("Task pool is full, task %s dropped", task.Name))
 			return ErrTaskPoolFull
-		}
-	case StrategyExpand:
-		tp.mu.Lock()
-		select {
-		case tp.queue <- task:
-			slog.Info(fmt.Sprintf("Task %s added to the pool", task.Name))
-		default:
+		case StrategyExpand:
 			slog.Info("Task pool is full, expanding...")
-			newTasks := make(chan *TaskJob, cap(tp.queue)*2)
-			close(tp.queue)
-			for t := range tp.queue {
-				newTasks <- t
+			tp.capacity *= 2
+		default:
+			if !wait {
+				slog.Info(fmt.Sprintf("Task pool is full, task %s queued over capacity", task.Name))
+				break
+			}
+			for len(tp.queue)+tp.reserved >= tp.capacity && tp.ctx.Err() == nil && !tp.draining {
+				tp.notFull.Wait()
+			}
+			if tp.draining {
+				return ErrTaskPoolDraining
+			}
+			if tp.ctx.Err() != nil {
+				return tp.ctx.Err()
 			}
-			tp.queue = newTasks
-			tp.queue <- task
-			slog.Info(fmt.Sprintf("Task pool expanded, task %s added", task.Name))
 		}
-		tp.mu.Unlock()
 	}
+	tp.reserved++
 	return nil
 }
 
-func (tp *TaskPool) start() {
-	for i := 0; i < Config.WorkerCount; i++ {
-		tp.wg.Add(1)
-		go tp.worker()
+// 归还admit预留的队列位置
+func (tp *TaskPool) release() {
+	tp.mu.Lock()
+	tp.reserved--
+	tp.notFull.Signal()
+	tp.mu.Unlock()
+}
+
+// 需要回报提交状态的仓库任务在排队时确定提交, pending状态才有提交可以回报,
+// 构建也检出这个提交而不是执行时引用的最新提交. 查询失败时由检出时确定
+func pinCommit(task *TaskJob) {
+	if task.Repo == "" || task.Commit != "" {
+		return
+	}
+	if forge, ok := taskForge(task.Name); !ok || forge.ForgeRepo == "" {
+		return
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	commit, err := ResolveRef(ctx, task.Repo, task.Ref)
+	if err != nil {
+		slog.Error(fmt.Sprintf("resolve %s of task %s: %v", task.Ref, task.Name, err))
+		return
+	}
+	task.Commit = commit
+}
+
+// 调用方需持有tp.mu
+func (tp *TaskPool) enqueue(id uint64, task *TaskJob) {
+	tp.queue = append(tp.queue, &queuedJob{id: id, job: task, queuedAt: time.Now()})
+	tp.states.Store(task.Name, TaskPending)
+	tp.notEmpty.Signal()
+}
+
+// 由构建记录还原运行参数
+func jobFromBuild(build *model.Build) *TaskJob {
+	task := &TaskJob{
+		Name:         build.TaskName,
+		PipeLine:     build.PipeLine,
+		Repo:         build.Repo,
+		Ref:          build.Ref,
+		PipelinePath: build.PipelinePath,
+		Branch:       build.Branch,
+		ChangeId:     build.ChangeId,
+		Commit:       build.Commit,
+		Revision:     build.Revision,
+		ResumeStep:   build.ResumeStep,
+	}
+	json.Unmarshal([]byte(build.Params), &task.Params)
+	json.Unmarshal([]byte(build.Causes), &task.Causes)
+	return task
+}
+
+// 记录任务状态并同步到构建记录, 会访问数据库和代码托管平台, 调用方不能持有tp.mu
+func (tp *TaskPool) setState(id uint64, task *TaskJob, state string) {
+	tp.states.Store(task.Name, state)
+	updates := map[string]any{"state": state}
+	now := time.Now()
+	switch {
+	case state == TaskRunning:
+		updates["started_at"] = now
+	case finishedState(state):
+		updates["finished_at"] = now
+	}
+	if state == TaskInterrupted {
+		// 关闭时被中断的构建在下次启动时重新排队
+		updates["requeue"] = Config.RequeueInterrupted
+	}
+	if result := Db.Model(&model.Build{}).Where("id = ?", id).Updates(updates); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+	reportStatus(id, task, state)
+}
+
+func (tp *TaskPool) start() {
+	tp.mu.Lock()
+	tp.spawnWorkers()
+	tp.mu.Unlock()
+	go tp.expireApprovals()
+	go func() {
+		// 唤醒所有等待中的worker和AddTask
+		<-tp.ctx.Done()
+		tp.mu.Lock()
+		tp.notEmpty.Broadcast()
+		tp.notFull.Broadcast()
+		tp.mu.Unlock()
+	}()
 }
 
 func (tp *TaskPool) Stop() {
 	tp.cancel()
-	// close(tp.queue)
 	tp.wg.Wait()
 }
 
-func (tp *TaskPool) worker() {
-	slog.Info("worker ...")
+// 优雅关闭: 停止接收新任务, 在grace时间内等待运行中的构建结束,
+// 超时仍在运行以及尚未开始的构建标记为interrupted
+func (tp *TaskPool) Shutdown(grace time.Duration) {
+	tp.mu.Lock()
+	tp.draining = true
+	tp.notFull.Broadcast()
+	tp.mu.Unlock()
+	slog.Info(fmt.Sprintf("Task pool shutting down, waiting up to %v for running builds", grace))
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		tp.mu.Lock()
+		running := len(tp.running)
+		tp.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	tp.Stop()
+
+	tp.mu.Lock()
+	queue := tp.queue
+	tp.queue = nil
+	tp.mu.Unlock()
+	for _, queued := range queue {
+		tp.setState(queued.id, queued.job, TaskInterrupted)
+	}
+	slog.Info("Task pool stopped")
+}
+
+// 启动时处理上次异常退出遗留的构建, 并按配置重新排队被中断的构建
+func (tp *TaskPool) recoverBuilds() {
+	// 上次异常退出时仍在排队或运行的构建, 以及上次关闭时被中断并标记了重新排队的构建
+	var builds []model.Build
+	result := Db.Where("state IN ? OR (state = ? AND requeue = ?)", []string{TaskPending, TaskRunning}, TaskInterrupted, true).Order("id").Find(&builds)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	if len(builds) == 0 {
+		return
+	}
+	ids := make([]uint, len(builds))
+	for i, build := range builds {
+		ids[i] = build.ID
+	}
+	result = Db.Model(&model.Build{}).Where("id IN ?", ids).Updates(map[string]any{"state": TaskInterrupted, "requeue": false})
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	// 只重新排队上面这些构建, 更早被中断的构建保持不变
+	if !Config.RequeueInterrupted {
+		for _, build := range builds {
+			RemoveBuildWorkspace(uint64(build.ID))
+		}
+		return
+	}
+	tasks := make([]*TaskJob, len(builds))
+	for i := range builds {
+		tasks[i] = jobFromBuild(&builds[i])
+		tp.setState(uint64(builds[i].ID), tasks[i], TaskPending)
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for i, build := range builds {
+		tp.enqueue(uint64(build.ID), tasks[i])
+		slog.Info(fmt.Sprintf("Task %s requeued, build: %d", build.TaskName, build.ID))
+	}
+	if len(tp.queue) > tp.capacity {
+		tp.capacity = len(tp.queue)
+	}
+}
+
+// 补齐编号在1..workers之间且未运行的worker, 调用方需持有tp.mu
+func (tp *TaskPool) spawnWorkers() {
+	for i := 1; i <= tp.workers; i++ {
+		if tp.alive[i] {
+			continue
+		}
+		tp.alive[i] = true
+		tp.wg.Add(1)
+		go tp.worker(i)
+	}
+}
+
+// 从队列头部取出一个任务, 池停止或worker被缩减时返回nil
+func (tp *TaskPool) dequeue(workerId int) *runningJob {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for tp.ctx.Err() == nil && workerId <= tp.workers && (tp.paused || tp.draining || len(tp.queue) == 0) {
+		tp.notEmpty.Wait()
+	}
+	if tp.ctx.Err() != nil || workerId > tp.workers {
+		delete(tp.alive, workerId)
+		return nil
+	}
+	queued := tp.queue[0]
+	tp.queue = tp.queue[1:]
+	tp.notFull.Signal()
+	running := &runningJob{id: queued.id, job: queued.job, startedAt: time.Now()}
+	tp.running[workerId] = running
+	return running
+}
+
+func (tp *TaskPool) worker(workerId int) {
+	slog.Info(fmt.Sprintf("worker %d ...", workerId))
 	defer tp.wg.Done()
 	for {
-		select {
-		case <-tp.ctx.Done():
+		running := tp.dequeue(workerId)
+		if running == nil {
+			slog.Info(fmt.Sprintf("worker %d exit", workerId))
 			return
-		case task, ok := <-tp.queue:
-			if !ok {
-				slog.Info(fmt.Sprintf("Task: %s in worker!!", task.Name))
-				return
-			}
-			ctx, cancel := context.WithCancel(tp.ctx)
-			tp.cancelFuncs.Store(task.Name, cancel)
-			slog.Info(fmt.Sprintf("run task: %v", task.Name))
-
-			tp.executeTask(ctx, task)
+		}
+		task := running.job
+		ctx, cancel := context.WithCancel(tp.ctx)
+		tp.cancelFuncs.Store(running.id, &runningCancel{name: task.Name, cancel: cancel})
+		slog.Info(fmt.Sprintf("run task: %v", task.Name))
 
-			tp.cancelFuncs.Delete(task.Name)
+		state := tp.executeTask(ctx, running.id, task)
+		if state != TaskCompleted && tp.ctx.Err() != nil {
+			state = TaskInterrupted
 		}
+		tp.setState(running.id, task, state)
+		if state == TaskCompleted {
+			tp.triggerDownstream(running.id, task)
+		}
+		// 等待审批的构建恢复后继续使用工作目录
+		if state != TaskWaiting {
+			RemoveBuildWorkspace(running.id)
+		}
+
+		cancel()
+		tp.cancelFuncs.Delete(running.id)
+		tp.mu.Lock()
+		delete(tp.running, workerId)
+		tp.mu.Unlock()
 	}
 }
 
+// 正在运行的构建的取消函数, 按构建ID保存
+type runningCancel struct {
+	name   string
+	cancel context.CancelFunc
+}
+
+// 取消任务正在运行的所有构建
 func (tp *TaskPool) CancelTask(taskName string) bool {
-	if cancel, ok := tp.cancelFuncs.Load(taskName); ok {
-		cancel.(context.CancelFunc)()
-		tp.cancelFuncs.Delete(taskName)
+	cancelled := false
+	tp.cancelFuncs.Range(func(id, value any) bool {
+		if running := value.(*runningCancel); running.name == taskName {
+			running.cancel()
+			tp.cancelFuncs.Delete(id)
+			cancelled = true
+		}
 		return true
+	})
+	return cancelled
+}
+
+// 暂停出队, 队列仍接收新任务
+func (tp *TaskPool) Pause() error {
+	if err := tp.updateState(func(state *model.PoolState) { state.Paused = true }); err != nil {
+		return err
 	}
-	return false
+	slog.Info("Task pool paused")
+	return nil
+}
+
+// 恢复出队并重新接收新任务
+func (tp *TaskPool) Resume() error {
+	err := tp.updateState(func(state *model.PoolState) {
+		state.Paused = false
+		state.Draining = false
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Task pool resumed")
+	return nil
+}
+
+// 排空: 正在运行的任务继续执行完毕, 不再接收和启动新任务.
+// 已排队的构建不会被丢弃, 保持pending直到Resume后继续执行, 重启后仍会恢复排队
+func (tp *TaskPool) Drain() error {
+	if err := tp.updateState(func(state *model.PoolState) { state.Draining = true }); err != nil {
+		return err
+	}
+	slog.Info("Task pool draining")
+	return nil
+}
+
+// 调整worker数量, 被缩减的worker执行完当前任务后退出
+func (tp *TaskPool) Resize(workers int) error {
+	if workers <= 0 {
+		return ErrWorkerCount
+	}
+	if err := tp.updateState(func(state *model.PoolState) { state.WorkerCount = workers }); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Task pool resized to %d workers", workers))
+	return nil
+}
+
+// 修改并持久化任务池状态. 在tp.mu内读取当前状态, 释放锁后写库, 写库成功才应用到内存,
+// 失败时任务池状态保持不变. stateMu保证并发的状态修改按顺序写库和应用
+func (tp *TaskPool) updateState(change func(state *model.PoolState)) error {
+	tp.stateMu.Lock()
+	defer tp.stateMu.Unlock()
+
+	tp.mu.Lock()
+	state := model.PoolState{Paused: tp.paused, Draining: tp.draining, WorkerCount: tp.workers}
+	tp.mu.Unlock()
+	change(&state)
+	state.ID = 1
+	if result := Db.Save(&state); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrSavePoolState
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	resized := tp.workers != state.WorkerCount
+	tp.paused = state.Paused
+	tp.draining = state.Draining
+	tp.workers = state.WorkerCount
+	if resized && tp.ctx.Err() == nil {
+		tp.spawnWorkers()
+	}
+	// 唤醒等待出队的worker(恢复或缩减)和等待入队的AddTask(排空)
+	tp.notEmpty.Broadcast()
+	tp.notFull.Broadcast()
+	return nil
+}
+
+// 加载上次持久化的任务池状态, 避免重启后维护窗口被意外解除
+func (tp *TaskPool) loadState() {
+	var state model.PoolState
+	result := Db.Where("id = ?", 1).Limit(1).Find(&state)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	tp.paused = state.Paused
+	tp.draining = state.Draining
+	if state.WorkerCount > 0 {
+		tp.workers = state.WorkerCount
+	}
+}
+
+// 移除尚未开始执行的排队任务
+func (tp *TaskPool) RemoveQueued(id uint64) bool {
+	tp.mu.Lock()
+	var removed *queuedJob
+	for i, queued := range tp.queue {
+		if queued.id == id {
+			tp.queue = append(tp.queue[:i], tp.queue[i+1:]...)
+			tp.notFull.Signal()
+			removed = queued
+			break
+		}
+	}
+	tp.mu.Unlock()
+	if removed == nil {
+		return false
+	}
+	tp.setState(removed.id, removed.job, TaskCancelled)
+	slog.Info(fmt.Sprintf("Task %s removed from the pool", removed.job.Name))
+	return true
 }
 
 func (tp *TaskPool) GetTaskStatus(name string) (string, bool) {
@@ -141,47 +571,179 @@ func (tp *TaskPool) GetTaskStatus(name string) (string, bool) {
 	return status.(string), true
 }
 
-type pipeLine struct {
-	Name  string `yaml:"name"`
-	Steps []step `yaml:"steps"`
+// 任务池快照
+func (tp *TaskPool) Snapshot() model.PoolInfo {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	now := time.Now()
+	info := model.PoolInfo{
+		Strategy: tp.strategy,
+		Capacity: tp.capacity,
+		Workers:  tp.workers,
+		Paused:   tp.paused,
+		Draining: tp.draining,
+		Queued:   make([]model.QueuedJobInfo, 0, len(tp.queue)),
+		Running:  make([]model.RunningJobInfo, 0, len(tp.running)),
+	}
+	for i, queued := range tp.queue {
+		info.Queued = append(info.Queued, model.QueuedJobInfo{
+			Id:       queued.id,
+			Name:     queued.job.Name,
+			Position: i + 1,
+			QueuedAt: queued.queuedAt,
+			WaitTime: int64(now.Sub(queued.queuedAt).Seconds()),
+		})
+	}
+	workerIds := make([]int, 0, len(tp.running))
+	for workerId := range tp.running {
+		workerIds = append(workerIds, workerId)
+	}
+	sort.Ints(workerIds)
+	for _, workerId := range workerIds {
+		running := tp.running[workerId]
+		info.Running = append(info.Running, model.RunningJobInfo{
+			Worker:    workerId,
+			Id:        running.id,
+			Name:      running.job.Name,
+			StartedAt: running.startedAt,
+			Elapsed:   int64(now.Sub(running.startedAt).Seconds()),
+		})
+	}
+	return info
 }
 
-type step struct {
-	Name    string `yaml:"name"`
-	Command string `yaml:"command"`
+// 从仓库检出流水线文件, 并在构建记录中保存所用的提交和流水线内容
+func (tp *TaskPool) loadRepoPipeline(ctx context.Context, id uint64, task *TaskJob, dir string) (string, error) {
+	commit, err := checkout(ctx, task.Repo, task.Ref, task.Commit, dir)
+	if err != nil {
+		return "", err
+	}
+	task.Commit = commit
+	path := task.PipelinePath
+	if path == "" {
+		path = DefaultPipelinePath
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Clean("/"+path)))
+	if err != nil {
+		return "", err
+	}
+	updates := map[string]any{"commit": commit, "pipe_line": string(data), "pipeline_path": path}
+	if result := Db.Model(&model.Build{}).Where("id = ?", id).Updates(updates); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+	slog.Info(fmt.Sprintf("Task %s loaded pipeline %s at commit %s", task.Name, path, commit))
+	return string(data), nil
 }
 
-func (tp *TaskPool) executeTask(ctx context.Context, task *TaskJob) {
-	var pipeline pipeLine
-	err := yaml.Unmarshal([]byte(task.PipeLine), &pipeline)
+// 执行流水线, 返回构建的最终状态
+func (tp *TaskPool) executeTask(ctx context.Context, id uint64, task *TaskJob) string {
+	data, dir := task.PipeLine, ""
+	// 审批通过后恢复的构建沿用已加载的流水线和工作目录
+	if task.Repo != "" && task.ResumeStep > 0 {
+		dir = BuildWorkspaceDir(id)
+	} else if task.Repo != "" {
+		dir = BuildWorkspaceDir(id)
+		var err error
+		data, err = tp.loadRepoPipeline(ctx, id, task, dir)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to load pipeline from repository: %v", err))
+			return TaskFailure
+		}
+	}
+	pipeline, err := parsePipeline(data)
 	if err != nil {
 		slog.Error(fmt.Sprintf("Failed to unmarshal pipeline: %v", err))
-		tp.states.Store(task.Name, TaskFailure)
-		return
+		return TaskFailure
+	}
+	// 入口处已校验, 这里防止升级前排队的构建带入保留变量
+	if err := ValidateParams(task.Params); err != nil {
+		slog.Error(fmt.Sprintf("Task %s: %v", task.Name, err))
+		return TaskFailure
+	}
+	env := os.Environ()
+	// 目录继承的设置在前, 构建参数可以覆盖
+	folderEnv, labels := FolderSettings(task.Name)
+	for key, value := range folderEnv {
+		env = append(env, key+"="+value)
 	}
-	for _, step := range pipeline.Steps {
+	if labels != "" {
+		env = append(env, "NODE_LABELS="+labels)
+	}
+	if task.Branch != "" {
+		env = append(env, "BRANCH_NAME="+task.Branch)
+	}
+	if task.ChangeId != "" {
+		env = append(env, "CHANGE_ID="+task.ChangeId)
+	}
+	for key, value := range task.Params {
+		env = append(env, key+"="+value)
+	}
+	tp.setState(id, task, TaskRunning)
+	for i, step := range pipeline.Steps {
+		if i < task.ResumeStep {
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			slog.Info(fmt.Sprintf("Cancelled: %v", step.Name))
-			tp.states.Store(task.Name, TaskCancelled)
-			return
+			return TaskCancelled
 		default:
-			tp.states.Store(task.Name, TaskRunning)
-			slog.Info(fmt.Sprintf("Executing step: %v, Command: %v", step.Name, step.Command))
-			cmd := exec.CommandContext(ctx, "bash", "-c", step.Command)
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				tp.states.Store(task.Name, TaskFailure)
-				slog.Error(fmt.Sprintf("Error executing command: %v, Output: %s", err, output))
-				return
+			if step.Approval != nil {
+				if err := tp.requestApproval(id, task, i, step.Name, step.Approval); err != nil {
+					slog.Error(fmt.Sprintf("Error requesting approval: %v, Step: %v", err, step.Name))
+					return TaskFailure
+				}
+				return TaskWaiting
+			}
+			if step.Trigger != nil {
+				if err := tp.runTrigger(ctx, id, task, step.Trigger); err != nil {
+					slog.Error(fmt.Sprintf("Error triggering task: %v, Step: %v", err, step.Name))
+					return TaskFailure
+				}
+				continue
+			}
+			if err := tp.runCommand(ctx, task, step, dir, env); err != nil {
+				return TaskFailure
 			}
-			slog.Info(fmt.Sprintf("Step: %v, Command: %v, Output: %s", step.Name, step.Command, output))
 		}
 	}
-	tp.states.Store(task.Name, TaskCompleted)
+	return TaskCompleted
+}
+
+// 执行命令步骤, 步骤引用的凭据只在本步骤内注入
+func (tp *TaskPool) runCommand(ctx context.Context, task *TaskJob, step step, dir string, env []string) error {
+	credentials, err := injectCredentials(task.Name, step.Credentials)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error injecting credentials: %v, Step: %v", err, step.Name))
+		return err
+	}
+	defer credentials.cleanup()
+	// 命令和输出都经过掩码后才写入日志
+	secrets := knownSecrets(task.Name)
+	command := maskString(secrets, step.Command)
+	slog.Info(fmt.Sprintf("Executing step: %v, Command: %v", step.Name, command))
+	cmd := exec.CommandContext(ctx, "bash", "-c", step.Command)
+	cmd.Dir = dir
+	cmd.Env = append(env[:len(env):len(env)], credentials.env...)
+	var output bytes.Buffer
+	masker := NewMaskWriter(&output, secrets)
+	cmd.Stdout = masker
+	cmd.Stderr = masker
+	err = cmd.Run()
+	masker.Close()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error executing command: %v, Output: %s", err, output.String()))
+		return err
+	}
+	slog.Info(fmt.Sprintf("Step: %v, Command: %v, Output: %s", step.Name, command, output.String()))
+	return nil
 }
 
-func init() {
+// 创建全局任务池, 恢复上次的运行时状态和遗留的构建并开始执行.
+// 只在启动服务时调用, 命令行子命令与正在运行的服务共用数据库, 不能接管其中的构建
+func StartTaskPool() {
 	Tp = NewTaskPool(context.Background())
+	Tp.loadState()
+	Tp.recoverBuilds()
 	go Tp.start()
 }
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gookins/model"
+)
+
+// 与coretest.OpenDb相同, 包内测试不能导入coretest
+func openTestDb(t *testing.T) {
+	t.Helper()
+	oldDb, oldDriver, oldPath := Db, Config.DbDriver, Config.DbPath
+	Config.DbDriver, Config.DbPath = DbSqlite, ":memory:"
+	if err := OpenDb(); err != nil {
+		t.Fatal(err)
+	}
+	db := Db
+	t.Cleanup(func() {
+		if sqlDb, err := db.DB(); err == nil {
+			sqlDb.Close()
+		}
+		Db, Config.DbDriver, Config.DbPath = oldDb, oldDriver, oldPath
+	})
+	if err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 内存数据库上的任务池, 不启动worker, 任务只排队不执行
+func newTestPool(t *testing.T, capacity int, strategy string) *TaskPool {
+	t.Helper()
+	openTestDb(t)
+	tp := NewTaskPool(context.Background())
+	tp.capacity, tp.strategy, tp.workers = capacity, strategy, 1
+	return tp
+}
+
+func buildState(t *testing.T, id uint64) string {
+	t.Helper()
+	var build model.Build
+	if result := Db.Where("id = ?", id).Limit(1).Find(&build); result.Error != nil || result.RowsAffected == 0 {
+		t.Fatalf("构建%d不存在: %v", id, result.Error)
+	}
+	return build.State
+}
+
+func TestAddTaskDropWhenFull(t *testing.T) {
+	tp := newTestPool(t, 1, StrategyDrop)
+	id, err := tp.AddTask(&TaskJob{Name: "build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tp.AddTask(&TaskJob{Name: "build"}); !errors.Is(err, ErrTaskPoolFull) {
+		t.Fatalf("队列满时 err = %v", err)
+	}
+	if !tp.RemoveQueued(id) {
+		t.Fatal("移除排队任务失败")
+	}
+	if state := buildState(t, id); state != TaskCancelled {
+		t.Fatalf("移除后构建状态为%s", state)
+	}
+	if _, err := tp.AddTask(&TaskJob{Name: "build"}); err != nil {
+		t.Fatalf("移除后应能再加入: %v", err)
+	}
+}
+
+// 阻塞策略下等待队列位置的AddTask在关闭时返回, 排队的构建标记为interrupted
+func TestShutdownInterruptsQueued(t *testing.T) {
+	tp := newTestPool(t, 1, StrategyBlock)
+	id, err := tp.AddTask(&TaskJob{Name: "build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := tp.AddTask(&TaskJob{Name: "build"})
+		blocked <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// 等待队列位置时不持有tp.mu, 快照仍可读取
+	if info := tp.Snapshot(); len(info.Queued) != 1 {
+		t.Fatalf("排队数为%d", len(info.Queued))
+	}
+	tp.Shutdown(0)
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrTaskPoolDraining) {
+			t.Fatalf("关闭时 err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("关闭后AddTask仍在等待")
+	}
+	if state := buildState(t, id); state != TaskInterrupted {
+		t.Fatalf("关闭后构建状态为%s", state)
+	}
+}
+
+func TestRecoverBuildsRequeue(t *testing.T) {
+	tp := newTestPool(t, 10, StrategyBlock)
+	old := Config.RequeueInterrupted
+	Config.RequeueInterrupted = true
+	t.Cleanup(func() { Config.RequeueInterrupted = old })
+	builds := []model.Build{
+		{TaskName: "old", State: TaskInterrupted},
+		{TaskName: "crashed", State: TaskRunning},
+		{TaskName: "shutdown", State: TaskInterrupted, Requeue: true},
+		{TaskName: "done", State: TaskCompleted},
+	}
+	if err := Db.Create(&builds).Error; err != nil {
+		t.Fatal(err)
+	}
+	tp.recoverBuilds()
+	if len(tp.queue) != 2 || tp.queue[0].job.Name != "crashed" || tp.queue[1].job.Name != "shutdown" {
+		t.Fatalf("重新排队的构建为%+v", tp.queue)
+	}
+	want := []string{TaskInterrupted, TaskPending, TaskPending, TaskCompleted}
+	for i, build := range builds {
+		if state := buildState(t, uint64(build.ID)); state != want[i] {
+			t.Errorf("构建%s的状态为%s, 应为%s", build.TaskName, state, want[i])
+		}
+	}
+	// 关闭时仍在排队的构建被中断, 下次启动时重新排队
+	tp.Shutdown(0)
+	if state := buildState(t, uint64(builds[1].ID)); state != TaskInterrupted {
+		t.Fatalf("关闭后构建状态为%s", state)
+	}
+	next := NewTaskPool(context.Background())
+	next.recoverBuilds()
+	if len(next.queue) != 2 {
+		t.Fatalf("下次启动时排队数为%d", len(next.queue))
+	}
+}
+
+// 同一任务的并发构建各自保存取消函数, 取消任务时全部取消
+func TestCancelTaskRunningBuilds(t *testing.T) {
+	tp := NewTaskPool(context.Background())
+	contexts := map[uint64]context.Context{}
+	for id, name := range map[uint64]string{1: "app", 2: "app", 3: "other"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		contexts[id] = ctx
+		tp.cancelFuncs.Store(id, &runningCancel{name: name, cancel: cancel})
+	}
+	if !tp.CancelTask("app") {
+		t.Fatal("取消失败")
+	}
+	if contexts[1].Err() == nil || contexts[2].Err() == nil || contexts[3].Err() != nil {
+		t.Fatal("应只取消app的构建")
+	}
+	if tp.CancelTask("app") {
+		t.Fatal("没有运行中的构建时应返回false")
+	}
+	if BuildWorkspaceDir(1) == BuildWorkspaceDir(2) {
+		t.Fatal("构建的工作目录相同")
+	}
+}
+
+// 状态写库失败时任务池保持原状态
+func TestPauseKeepsStateWhenSaveFails(t *testing.T) {
+	tp := newTestPool(t, 1, StrategyBlock)
+	if err := tp.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Db.Migrator().DropTable(&model.PoolState{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Pause(); !errors.Is(err, ErrSavePoolState) {
+		t.Fatalf("写库失败时 err = %v", err)
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.paused || !tp.draining {
+		t.Fatalf("写库失败后 paused = %v, draining = %v", tp.paused, tp.draining)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateParams(t *testing.T) {
+	t.Setenv("GOOKINS_TEST_SECRET", "x")
+	if err := ValidateParams(map[string]string{"VERSION": "1", "_flag2": ""}); err != nil {
+		t.Fatalf("合法参数被拒绝: %v", err)
+	}
+	for _, name := range []string{"", "1A", "A-B", "A=B", "PATH", "BASH_ENV", "LD_PRELOAD", "BRANCH_NAME", "GOOKINS_TEST_SECRET"} {
+		if err := ValidateParams(map[string]string{name: "v"}); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("参数%q err = %v", name, err)
+		}
+	}
+}
+
+func TestRenameTriggerTargets(t *testing.T) {
+	pipeline := `steps:
+  # 构建后部署
+  - name: deploy
+    trigger:
+      task: app/deploy   # 生产环境
+      wait: true
+  - {name: 测试, trigger: {task: 'app/测试'}}
+  - name: other
+    trigger:
+      task: "application"
+`
+	renamed, changed := RenameTriggerTargets(pipeline, "app", "web/应用", true)
+	if !changed {
+		t.Fatal("应改写trigger目标")
+	}
+	want := `steps:
+  # 构建后部署
+  - name: deploy
+    trigger:
+      task: web/应用/deploy   # 生产环境
+      wait: true
+  - {name: 测试, trigger: {task: 'web/应用/测试'}}
+  - name: other
+    trigger:
+      task: "application"
+`
+	if renamed != want {
+		t.Fatalf("改写结果为\n%s", renamed)
+	}
+	if _, changed := RenameTriggerTargets(pipeline, "app/deploy", "ops/deploy", false); !changed {
+		t.Fatal("应改写单个任务的trigger目标")
+	}
+	if got, changed := RenameTriggerTargets(pipeline, "ops", "x", true); changed || got != pipeline {
+		t.Fatal("没有引用时不应改写")
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gookins/model"
+)
+
+// 个人API令牌的前缀, 用于和JWT区分
+const ApiTokenPrefix = "gk_"
+
+var (
+	ErrApiTokenInvalid = errors.New("API令牌无效或已吊销")
+	ErrApiTokenExpire  = errors.New("API令牌已经过期")
+)
+
+// 各令牌范围允许的权限
+var tokenScopePermissions = map[string][]string{
+	model.TokenScopeRead: {PermView},
+	model.TokenScopeRun:  {PermView, PermRun},
+}
+
+// 令牌的SHA-256哈希, API令牌和webhook令牌在数据库中都只保存哈希
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成新的API令牌明文
+func NewApiToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return ApiTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+func IsApiToken(tokenStr string) bool {
+	return strings.HasPrefix(tokenStr, ApiTokenPrefix)
+}
+
+// 验证API令牌, 返回用户名和令牌范围, 并记录最后使用时间
+func ParseApiToken(tokenStr string) (string, string, error) {
+	var token model.ApiToken
+	result := Db.Where("token_hash = ?", HashApiToken(tokenStr)).Limit(1).Find(&token)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return "", "", ErrApiTokenInvalid
+	}
+	if result.RowsAffected == 0 {
+		return "", "", ErrApiTokenInvalid
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return "", "", ErrApiTokenExpire
+	}
+	if result := Db.Model(&token).UpdateColumn("last_used_at", time.Now()); result.Error != nil {
+		slog.Error(result.Error.Error())
+	}
+	return token.UserName, token.Scope, nil
+}
+
+// 令牌范围是否允许权限perm, 完整范围(JWT或不限范围的令牌)允许所有权限
+func scopeAllows(scope, perm string) bool {
+	if scope == model.TokenScopeFull {
+		return true
+	}
+	for _, p := range tokenScopePermissions[scope] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gookins/model"
+)
+
+var (
+	ErrTriggerCycle    = errors.New("任务触发形成环")
+	ErrTriggerNotFound = errors.New("触发的任务不存在")
+	ErrTriggerDisabled = errors.New("触发的任务已禁用")
+	ErrTriggerFailed   = errors.New("触发的任务没有成功")
+	ErrTriggerWait     = errors.New("没有空闲的worker执行等待的构建, 请增加worker数量或去掉wait")
+)
+
+// 下游构建等待结果时的轮询间隔
+const triggerWaitInterval = time.Second
+
+// 按逗号分隔的任务名列表是否包含name
+func ContainsTaskName(names, name string) bool {
+	for _, n := range strings.Split(names, ",") {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 由数据库中的任务生成运行参数
+func JobFromTask(task *model.Task) *TaskJob {
+	return &TaskJob{
+		Id:           fmt.Sprint(task.ID),
+		Name:         task.Name,
+		PipeLine:     task.PipeLine,
+		Repo:         task.Repo,
+		Ref:          task.Ref,
+		PipelinePath: task.PipelinePath,
+		Branch:       task.Branch,
+		ChangeId:     task.ChangeId,
+		Revision:     task.Revision,
+	}
+}
+
+// 触发任务name, causes为触发链, 若name已在链中则拒绝以避免环
+func (tp *TaskPool) trigger(name string, params map[string]string, causes []model.Cause) (uint64, error) {
+	for _, cause := range causes {
+		if cause.TaskName == name {
+			return 0, ErrTriggerCycle
+		}
+	}
+	var task model.Task
+	result := Db.Where("name = ?", name).Limit(1).Find(&task)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrTriggerNotFound
+	}
+	if task.Disabled {
+		return 0, ErrTriggerDisabled
+	}
+	if err := ValidateParams(params); err != nil {
+		return 0, err
+	}
+	job := JobFromTask(&task)
+	job.Params = params
+	job.Causes = causes
+	// 只在worker中触发, 不能等待队列位置
+	return tp.addTask(job, false)
+}
+
+// 当前构建加入触发链后的结果
+func nextCauses(id uint64, task *TaskJob) []model.Cause {
+	causes := make([]model.Cause, 0, len(task.Causes)+1)
+	causes = append(causes, task.Causes...)
+	return append(causes, model.Cause{TaskName: task.Name, BuildId: id})
+}
+
+// 执行流水线中的trigger步骤
+func (tp *TaskPool) runTrigger(ctx context.Context, id uint64, task *TaskJob, step *triggerStep) error {
+	if step.Wait {
+		if err := tp.reserveWait(); err != nil {
+			return err
+		}
+		defer tp.releaseWait()
+	}
+	buildId, err := tp.trigger(step.Task, step.Params, nextCauses(id, task))
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Task %s triggered %s, build: %d", task.Name, step.Task, buildId))
+	if !step.Wait {
+		return nil
+	}
+	ticker := time.NewTicker(triggerWaitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		var build model.Build
+		if result := Db.Select("state").Where("id = ?", buildId).Limit(1).Find(&build); result.Error != nil {
+			return result.Error
+		}
+		if build.State == TaskCompleted {
+			return nil
+		}
+		if finishedState(build.State) {
+			return fmt.Errorf("%w: %s #%d %s", ErrTriggerFailed, step.Task, buildId, build.State)
+		}
+	}
+}
+
+// 等待下游构建的worker不能执行其他构建, 至少留一个worker不在等待, 下游构建才能执行
+func (tp *TaskPool) reserveWait() error {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.waiting+1 >= tp.workers {
+		return ErrTriggerWait
+	}
+	tp.waiting++
+	return nil
+}
+
+func (tp *TaskPool) releaseWait() {
+	tp.mu.Lock()
+	tp.waiting--
+	tp.mu.Unlock()
+}
+
+// 构建成功后触发配置了trigger_after的下游任务
+func (tp *TaskPool) triggerDownstream(id uint64, task *TaskJob) {
+	var tasks []model.Task
+	if result := Db.Where("trigger_after <> ''").Find(&tasks); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return
+	}
+	causes := nextCauses(id, task)
+	for _, downstream := range tasks {
+		if !ContainsTaskName(downstream.TriggerAfter, task.Name) {
+			continue
+		}
+		buildId, err := tp.trigger(downstream.Name, task.Params, causes)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Task %s trigger downstream %s: %v", task.Name, downstream.Name, err))
+			continue
+		}
+		slog.Info(fmt.Sprintf("Task %s triggered downstream %s, build: %d", task.Name, downstream.Name, buildId))
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gookins/model"
+)
+
+// 等待构建结束, 返回最终状态
+func waitBuild(t *testing.T, id uint64) string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		switch state := buildState(t, id); state {
+		case TaskPending, TaskRunning:
+			time.Sleep(50 * time.Millisecond)
+		default:
+			return state
+		}
+	}
+	t.Fatalf("构建%d没有结束", id)
+	return ""
+}
+
+func runTriggerPipeline(t *testing.T, workers int) string {
+	t.Helper()
+	tp := newTestPool(t, 10, StrategyBlock)
+	tp.workers = workers
+	tasks := []model.Task{
+		{Name: "down", PipeLine: "steps:\n  - name: a\n    command: \"true\"\n"},
+		{Name: "up", PipeLine: "steps:\n  - name: t\n    trigger:\n      task: down\n      wait: true\n"},
+	}
+	if err := Db.Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	tp.start()
+	t.Cleanup(tp.Stop)
+	id, err := tp.AddTask(JobFromTask(&tasks[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return waitBuild(t, id)
+}
+
+// 只有一个worker时等待下游构建会死锁, 应直接失败
+func TestTriggerWaitSingleWorker(t *testing.T) {
+	if state := runTriggerPipeline(t, 1); state != TaskFailure {
+		t.Fatalf("构建状态为%s", state)
+	}
+}
+
+func TestTriggerWait(t *testing.T) {
+	if state := runTriggerPipeline(t, 2); state != TaskCompleted {
+		t.Fatalf("构建状态为%s", state)
+	}
+}
+
+func TestReserveWaitKeepsOneWorker(t *testing.T) {
+	tp := NewTaskPool(context.Background())
+	tp.workers = 3
+	for i := 0; i < 2; i++ {
+		if err := tp.reserveWait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tp.reserveWait(); !errors.Is(err, ErrTriggerWait) {
+		t.Fatalf("err = %v", err)
+	}
+	tp.releaseWait()
+	if err := tp.reserveWait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// worker中触发的构建在队列满时不等待
+func TestTriggerDoesNotBlockWhenFull(t *testing.T) {
+	tp := newTestPool(t, 1, StrategyBlock)
+	if err := Db.Create(&model.Task{Name: "down", PipeLine: "steps:\n  - name: a\n    command: \"true\"\n"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tp.AddTask(&TaskJob{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := tp.trigger("down", nil, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("队列满时触发被阻塞")
+	}
+	if len(tp.queue) != 2 {
+		t.Fatalf("排队数为%d", len(tp.queue))
+	}
+}
@@ -1,9 +1,6 @@
 package core
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
 	"log/slog"
 	"net/http"
 	"time"
@@ -14,24 +11,11 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-// 哈希用户密码
-func HashPassword(password string) string {
-	h := hmac.New(sha256.New, []byte(Config.SaltKey))
-	h.Write([]byte(password))
-	hashedPasswd := h.Sum(nil)
-	return base64.StdEncoding.EncodeToString(hashedPasswd)
-}
-
-// 验证用户密码
-func VerifyPassword(hashPasswd, password string) bool {
-	newHash := HashPassword(password)
-	return hmac.Equal([]byte(hashPasswd), []byte(newHash))
-}
-
-// 生成token
-func CreateToken(username string) (string, error) {
+// 生成token, sid为登录会话ID, 会话被吊销后token随之失效
+func CreateToken(username string, sid uint) (string, error) {
 	claims := &jwt.MapClaims{
 		"username": username,
+		"sid":      sid,
 		"exp":      time.Now().Add(time.Minute * time.Duration(Config.ExpiredTime)).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -43,7 +27,7 @@ func CreateToken(username string) (string, error) {
 	return tokenStr, nil
 }
 
-func ParseToken(tokenStr string) (string, error) {
+func ParseToken(tokenStr string) (string, uint, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		// 确保签名方法是我们所期望的
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -53,34 +37,54 @@ func ParseToken(tokenStr string) (string, error) {
 	})
 	if err != nil {
 		slog.Error(err.Error())
-		return "", ErrParseToken
+		return "", 0, ErrParseToken
 	}
 	// 读取 claims
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		// 检查过期时间
 		if exp, ok := claims["exp"].(float64); ok {
 			if time.Unix(int64(exp), 0).Before(time.Now()) {
-				return "", ErrTokenExpire
+				return "", 0, ErrTokenExpire
 			}
 		}
-		// 返回用户名
-		if username, ok := claims["username"].(string); ok {
-			return username, nil
+		// 返回用户名和会话ID
+		username, ok := claims["username"].(string)
+		sid, _ := claims["sid"].(float64)
+		if ok && sid > 0 {
+			return username, uint(sid), nil
 		}
 	}
-	return "", ErrTokenVaild
+	return "", 0, ErrTokenVaild
 }
 
 // curl -H "Authorization: valid-token"
 func AuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		tokenStr := ctx.GetHeader("Authorization")
-		_, err := ParseToken(tokenStr)
+		var username, scope string
+		var sid uint
+		var err error
+		if IsApiToken(tokenStr) {
+			username, scope, err = ParseApiToken(tokenStr)
+		} else {
+			username, sid, err = ParseToken(tokenStr)
+			if err == nil {
+				err = checkSession(sid, username)
+			}
+		}
+		// 用户被删除或禁用后立即拒绝
+		if err == nil {
+			err = checkUser(username)
+		}
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, model.ApiRespone{Code: http.StatusUnauthorized, Message: err.Error()})
 			ctx.Abort()
 			return
 		}
+		// 供后续处理函数获取当前用户
+		ctx.Set(UsernameKey, username)
+		ctx.Set(TokenScopeKey, scope)
+		ctx.Set(SessionKey, sid)
 		ctx.Next()
 	}
 }
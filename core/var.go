@@ -2,14 +2,20 @@ package core
 
 import (
 	"errors"
-	"sync"
 
 	"gorm.io/gorm"
 )
 
-var (
-	once sync.Once
+// gin上下文中保存当前用户名的键
+const UsernameKey = "username"
+
+// gin上下文中保存API令牌范围的键, JWT登录时为空
+const TokenScopeKey = "token_scope"
 
+// gin上下文中保存登录会话ID的键
+const SessionKey = "session_id"
+
+var (
 	Db     *gorm.DB
 	Config config
 	Tp     *TaskPool
@@ -19,4 +25,9 @@ var (
 	ErrParseToken     = errors.New("验证token失败")
 	ErrTokenExpire    = errors.New("token已经过期")
 	ErrTokenVaild     = errors.New("token不可用")
+	ErrSessionRevoked = errors.New("登录会话已失效")
+	ErrRefreshToken   = errors.New("刷新令牌无效或已过期")
+	ErrUserInactive   = errors.New("用户不存在或已禁用")
+	ErrSavePoolState  = errors.New("保存任务池状态失败")
+	ErrCreateBuild    = errors.New("创建构建记录失败")
 )
@@ -24,9 +24,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
+        "/approval/approve/{id}": {
             "post": {
-                "description": "用户登录接口",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "通过审批后构建继续执行",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,29 +39,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "审批"
                 ],
-                "summary": "用户登录",
+                "summary": "通过审批",
                 "parameters": [
                     {
-                        "description": "登录请求参数",
-                        "name": "login",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.LoginForm"
-                        }
+                        "type": "string",
+                        "description": "审批ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功返回Token",
+                        "description": "审批通过",
                         "schema": {
-                            "$ref": "#/definitions/model.LoginRespon"
+                            "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "无效的凭据",
+                        "description": "审批失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -64,14 +67,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/add": {
-            "post": {
+        "/approval/list": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务创建接口",
+                "description": "审批列表接口, 可按状态过滤",
                 "consumes": [
                     "application/json"
                 ],
@@ -79,29 +82,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "审批"
                 ],
-                "summary": "创建任务",
+                "summary": "审批列表",
                 "parameters": [
                     {
-                        "description": "创建任务请求参数",
-                        "name": "task",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.TaskForm"
-                        }
+                        "type": "string",
+                        "description": "pending|approved|rejected|timeout",
+                        "name": "state",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "创建任务成功",
+                        "description": "获取审批列表成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "创建任务失败",
+                        "description": "获取审批列表失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -109,14 +109,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/cancel/{name}": {
+        "/approval/reject/{id}": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务禁用接口",
+                "description": "拒绝审批后构建终止",
                 "consumes": [
                     "application/json"
                 ],
@@ -124,27 +124,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "审批"
                 ],
-                "summary": "禁用任务",
+                "summary": "拒绝审批",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "name",
-                        "name": "name",
+                        "description": "审批ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "取消任务成功",
+                        "description": "审批已拒绝",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "取消任务失败",
+                        "description": "审批失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -152,42 +152,62 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/del/{id}": {
-            "delete": {
+        "/audit/export": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务删除接口",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "以JSON lines格式导出审计日志, 过滤条件同列表接口",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "任务"
+                    "审计"
                 ],
-                "summary": "删除任务",
+                "summary": "导出审计日志",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "执行者",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "动作, 模糊匹配",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "对象, 模糊匹配",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "开始时间(RFC3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "结束时间(RFC3339)",
+                        "name": "until",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "删除任务成功",
+                        "description": "每行一条JSON",
                         "schema": {
-                            "$ref": "#/definitions/model.ApiRespone"
+                            "type": "string"
                         }
                     },
                     "500": {
-                        "description": "删除任务失败",
+                        "description": "导出审计日志失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -195,44 +215,74 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/hook": {
-            "post": {
+        "/audit/list": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "hook运行任务接口",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "按执行者、动作、对象和时间范围分页查询审计日志",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "审计"
                 ],
-                "summary": "hook运行任务",
+                "summary": "审计日志列表",
                 "parameters": [
                     {
-                        "description": "hook添加任务请求参数",
-                        "name": "task",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.TaskForm"
-                        }
+                        "type": "string",
+                        "description": "执行者",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "动作, 模糊匹配",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "对象, 模糊匹配",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "开始时间(RFC3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "结束时间(RFC3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页条数",
+                        "name": "size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "hook添加任务到任务池成功",
+                        "description": "获取审计日志成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
-                    "401": {
-                        "description": "hook添加任务到任务池失败",
+                    "500": {
+                        "description": "获取审计日志失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -240,14 +290,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/list": {
-            "get": {
+        "/credential/add": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务列表接口",
+                "description": "凭据创建接口, 内容加密存储",
                 "consumes": [
                     "application/json"
                 ],
@@ -255,18 +305,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "凭据"
+                ],
+                "summary": "创建凭据",
+                "parameters": [
+                    {
+                        "description": "创建凭据请求参数",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CredentialForm"
+                        }
+                    }
                 ],
-                "summary": "任务列表",
                 "responses": {
                     "200": {
-                        "description": "获取任务成功",
+                        "description": "创建凭据成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "获取任务失败",
+                        "description": "创建凭据失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -274,14 +335,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/run": {
-            "post": {
+        "/credential/del/{id}": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "运行任务接口",
+                "description": "凭据删除接口",
                 "consumes": [
                     "application/json"
                 ],
@@ -289,29 +350,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "凭据"
                 ],
-                "summary": "运行任务",
+                "summary": "删除凭据",
                 "parameters": [
                     {
-                        "description": "添加务请求参数",
-                        "name": "task",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.TaskForm"
-                        }
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "添加任务到任务池成功",
+                        "description": "删除凭据成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "添加任务到任务池失败",
+                        "description": "删除凭据失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -319,14 +378,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/state/{name}": {
+        "/credential/list": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务状态接口",
+                "description": "凭据列表接口, 不返回凭据内容",
                 "consumes": [
                     "application/json"
                 ],
@@ -334,27 +393,18 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
-                ],
-                "summary": "任务状态",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    }
+                    "凭据"
                 ],
+                "summary": "凭据列表",
                 "responses": {
                     "200": {
-                        "description": "获取任务状态成功",
+                        "description": "获取凭据列表成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
-                    "401": {
-                        "description": "获取任务状态失败",
+                    "500": {
+                        "description": "获取凭据列表失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -362,14 +412,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/upt/{id}": {
+        "/credential/upt": {
             "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "任务更新接口",
+                "description": "凭据更新接口",
                 "consumes": [
                     "application/json"
                 ],
@@ -377,29 +427,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "任务"
+                    "凭据"
                 ],
-                "summary": "更新任务",
+                "summary": "更新凭据",
                 "parameters": [
                     {
-                        "description": "更新任务请求参数",
-                        "name": "task",
+                        "description": "更新凭据请求参数",
+                        "name": "credential",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.TaskForm"
+                            "$ref": "#/definitions/model.CredentialForm"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "更新任务成功",
+                        "description": "更新凭据成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "更新任务失败",
+                        "description": "更新凭据失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -407,14 +457,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/add": {
+        "/folder/add": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "用户创建接口",
+                "description": "创建目录, 上级目录必须已存在",
                 "consumes": [
                     "application/json"
                 ],
@@ -422,29 +472,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "目录"
                 ],
-                "summary": "创建用户",
+                "summary": "创建目录",
                 "parameters": [
                     {
-                        "description": "创建用户请求参数",
-                        "name": "user",
+                        "description": "创建目录请求参数",
+                        "name": "folder",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.UserForm"
+                            "$ref": "#/definitions/model.FolderForm"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "创建用户成功",
+                        "description": "创建目录成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "创建用户失败",
+                        "description": "创建目录失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -452,42 +502,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/del/{id}": {
+        "/folder/del/{path}": {
             "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "用户删除接口",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "删除空目录",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "目录"
                 ],
-                "summary": "删除用户",
+                "summary": "删除目录",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "用户ID",
-                        "name": "id",
+                        "description": "目录",
+                        "name": "path",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "删除用户成功",
+                        "description": "删除目录成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "删除用户失败",
+                        "description": "删除目录失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -495,14 +542,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/list": {
+        "/folder/list": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "用户列表接口",
+                "description": "列出目录下当前用户有查看权限的直接子目录, path为空时列出顶层目录",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "目录"
+                ],
+                "summary": "目录列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级目录",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取目录列表成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取目录列表失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/folder/move": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "移动或重命名目录, 目录下的任务、构建记录、凭据和角色范围随之更新, 其他任务保存的流水线中的trigger目标也随之改写",
                 "consumes": [
                     "application/json"
                 ],
@@ -510,18 +596,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "目录"
+                ],
+                "summary": "移动目录",
+                "parameters": [
+                    {
+                        "description": "原目录和新目录",
+                        "name": "move",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MoveForm"
+                        }
+                    }
                 ],
-                "summary": "用户列表",
                 "responses": {
                     "200": {
-                        "description": "获取用户列表成功",
+                        "description": "移动目录成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "获取用户列表失败",
+                        "description": "移动目录失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
@@ -529,14 +626,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/upt/{id}": {
+        "/folder/upt": {
             "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "用户更新接口",
+                "description": "更新目录的描述、继承的环境变量和执行节点标签",
                 "consumes": [
                     "application/json"
                 ],
@@ -544,42 +641,1905 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "目录"
                 ],
-                "summary": "更新用户",
+                "summary": "更新目录",
                 "parameters": [
                     {
-                        "description": "更新用户请求参数",
-                        "name": "user",
+                        "description": "更新目录请求参数",
+                        "name": "folder",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.UserForm"
+                            "$ref": "#/definitions/model.FolderForm"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "更新用户成功",
+                        "description": "更新目录成功",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     },
                     "500": {
-                        "description": "更新用户失败",
+                        "description": "更新目录失败",
                         "schema": {
                             "$ref": "#/definitions/model.ApiRespone"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.ApiRespone": {
-            "type": "object",
-            "properties": {
-                "code": {
+        },
+        "/login": {
+            "post": {
+                "description": "用户登录接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "用户登录",
+                "parameters": [
+                    {
+                        "description": "登录请求参数",
+                        "name": "login",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回Token",
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginRespon"
+                        }
+                    },
+                    "429": {
+                        "description": "登录尝试过于频繁",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "无效的凭据",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "吊销当前登录会话, 访问token和刷新令牌立即失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "用户登出",
+                "responses": {
+                    "200": {
+                        "description": "登出成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "登出失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/callback": {
+            "get": {
+                "description": "IdP授权后的回调, 校验身份令牌后创建登录会话",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "OIDC回调",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "授权码",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "登录状态",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回Token",
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginRespon"
+                        }
+                    },
+                    "401": {
+                        "description": "登录失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/login": {
+            "get": {
+                "description": "跳转到OIDC身份提供方进行授权码+PKCE登录",
+                "tags": [
+                    "用户"
+                ],
+                "summary": "OIDC登录",
+                "responses": {
+                    "302": {
+                        "description": "跳转到IdP"
+                    },
+                    "500": {
+                        "description": "未启用或IdP不可用",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务池策略、容量、排队和运行中的任务, 只列出当前用户有查看权限的任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "任务池概览",
+                "responses": {
+                    "200": {
+                        "description": "获取任务池信息成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.ApiRespone"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.PoolInfo"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/drain": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "正在运行的任务执行完毕, 不再接收和启动新任务, 已排队的构建保持pending直到恢复任务池",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "排空任务池",
+                "responses": {
+                    "200": {
+                        "description": "排空任务池成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "排空任务池失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/pause": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "暂停出队, 队列仍接收新任务, 状态在重启后保留",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "暂停任务池",
+                "responses": {
+                    "200": {
+                        "description": "暂停任务池成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "暂停任务池失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/queue": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "排队任务及其位置和等待时间, 只列出当前用户有查看权限的任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "排队任务列表",
+                "responses": {
+                    "200": {
+                        "description": "获取排队任务成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.ApiRespone"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.QueuedJobInfo"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/queue/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "在任务开始执行前将其从队列中移除",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "移除排队任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "排队任务ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "移除排队任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "移除排队任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/resize": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "运行时调整并发worker数量",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "调整worker数量",
+                "parameters": [
+                    {
+                        "description": "worker数量",
+                        "name": "resize",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PoolResizeForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "调整worker数量成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "调整worker数量失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "恢复出队并重新接收新任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "恢复任务池",
+                "responses": {
+                    "200": {
+                        "description": "恢复任务池成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "恢复任务池失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/pool/workers": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "每个worker上正在运行的任务及已运行时间, 只列出当前用户有查看权限的任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务池"
+                ],
+                "summary": "运行任务列表",
+                "responses": {
+                    "200": {
+                        "description": "获取运行任务成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.ApiRespone"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.RunningJobInfo"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/refresh": {
+            "post": {
+                "description": "使用刷新令牌换取新的访问token, 刷新令牌同时轮换",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "刷新token",
+                "parameters": [
+                    {
+                        "description": "刷新令牌",
+                        "name": "refresh",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RefreshForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回Token",
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginRespon"
+                        }
+                    },
+                    "401": {
+                        "description": "刷新令牌无效",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/role/add": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为用户在全局或任务范围内绑定角色",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "角色"
+                ],
+                "summary": "创建角色绑定",
+                "parameters": [
+                    {
+                        "description": "角色绑定请求参数",
+                        "name": "binding",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RoleBindingForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建角色绑定成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "创建角色绑定失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/role/del/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "角色绑定删除接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "角色"
+                ],
+                "summary": "删除角色绑定",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "删除角色绑定成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "删除角色绑定失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/role/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "角色绑定列表接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "角色"
+                ],
+                "summary": "角色绑定列表",
+                "responses": {
+                    "200": {
+                        "description": "获取角色绑定列表成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取角色绑定列表失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/add": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务创建接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "创建任务",
+                "parameters": [
+                    {
+                        "description": "创建任务请求参数",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TaskForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "创建任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/cancel/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务取消接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "取消任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "取消任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "取消任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/del/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务删除接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "删除任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "删除任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "删除任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/diff/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "两个修订版本之间的统一格式diff, to为空时与当前版本比较",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "修订版本对比",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "任务名",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "旧版本号",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "新版本号",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "对比修订版本成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "修订版本不存在",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/disable/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务禁用接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "禁用任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "禁用任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "禁用任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "导出单个任务、一个目录或全部任务(流水线、描述、触发规则和设置), 只包含凭据引用不包含凭据值. 只导出当前用户有查看权限的任务",
+                "produces": [
+                    "application/x-yaml"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "导出任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "任务名",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "目录",
+                        "name": "folder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "yaml(默认)或tar(tar.gz)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "导出文件",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "导出任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/hook": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "hook运行任务接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "hook运行任务",
+                "parameters": [
+                    {
+                        "description": "hook添加任务请求参数",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TaskForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "hook添加任务到任务池成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "401": {
+                        "description": "hook添加任务到任务池失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "导入导出的YAML或tar.gz文件. conflict为同名任务的处理方式: skip(默认)、overwrite或rename; dry_run为true时只检查并返回报告, 不写入",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "导入任务",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "导出文件",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "试运行",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "skip、overwrite或rename",
+                        "name": "conflict",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "导入报告",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "导入任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务列表接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "任务列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "只列出该目录下的任务",
+                        "name": "folder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "包含子目录中的任务",
+                        "name": "recursive",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/move": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "移动或重命名任务, 构建记录、修订版本、凭据和角色范围随之更新, 其他任务保存的流水线中的trigger目标也随之改写",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "移动任务",
+                "parameters": [
+                    {
+                        "description": "原任务名和新任务名",
+                        "name": "move",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MoveForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "移动任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "移动任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/restore/{revision}/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "将任务流水线恢复为指定版本, 恢复操作记录为新版本",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "恢复修订版本",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "任务名",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "版本号",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "恢复修订版本成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "恢复修订版本失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/revision/{revision}/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "查看任务某个修订版本的流水线",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "修订版本详情",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "任务名",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "版本号",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取修订版本成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "修订版本不存在",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/revisions/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务流水线的修订版本列表, 最新的在前",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "修订版本列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "任务名",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取修订版本成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取修订版本失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/run": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "运行任务接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "运行任务",
+                "parameters": [
+                    {
+                        "description": "运行任务请求参数",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RunForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "添加任务到任务池成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "添加任务到任务池失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/scan/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "扫描仓库分支和合并请求, 同步子任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "扫描多分支任务",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "扫描多分支任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "扫描多分支任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/state/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务状态接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "任务状态",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "获取任务状态成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "401": {
+                        "description": "获取任务状态失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/upt/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "任务更新接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "更新任务",
+                "parameters": [
+                    {
+                        "description": "更新任务请求参数",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TaskForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "更新任务成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "更新任务失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/webhook/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为任务生成新的通用webhook令牌, 旧地址随之失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "生成webhook地址",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "生成webhook地址成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "生成webhook地址失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/add": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为当前用户创建长期API令牌, 明文只返回一次",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API令牌"
+                ],
+                "summary": "创建API令牌",
+                "parameters": [
+                    {
+                        "description": "创建API令牌请求参数",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiTokenForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建API令牌成功",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.ApiRespone"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.ApiTokenRespon"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "创建API令牌失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/del/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "吊销当前用户的API令牌",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API令牌"
+                ],
+                "summary": "吊销API令牌",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "吊销API令牌成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "吊销API令牌失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "当前用户的API令牌及最后使用时间",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API令牌"
+                ],
+                "summary": "API令牌列表",
+                "responses": {
+                    "200": {
+                        "description": "获取API令牌列表成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取API令牌列表失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/add": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "用户创建接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "创建用户",
+                "parameters": [
+                    {
+                        "description": "创建用户请求参数",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "创建用户成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "创建用户失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/del/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "用户删除接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "删除用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "删除用户成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "删除用户失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/disable/{id}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "禁用或启用用户, 禁用后其会话立即失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "禁用用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "更新用户状态成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "更新用户状态失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "用户列表接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "用户列表",
+                "responses": {
+                    "200": {
+                        "description": "获取用户列表成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "获取用户列表失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/unlock/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "清除账号的登录失败计数, 解除锁定",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "解锁用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户名",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "解锁用户成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/upt/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "用户更新接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "更新用户",
+                "parameters": [
+                    {
+                        "description": "更新用户请求参数",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserForm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "更新用户成功",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "500": {
+                        "description": "更新用户失败",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhook/{token}": {
+            "post": {
+                "description": "由外部系统调用, 按任务配置提取参数并触发构建",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "任务"
+                ],
+                "summary": "通用webhook触发",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "webhook令牌",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "触发构建成功, data为构建ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook不存在",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiRespone"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.ApiRespone": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "integer"
                 },
                 "data": {},
@@ -588,6 +2548,103 @@ const docTemplate = `{
                 }
             }
         },
+        "model.ApiTokenForm": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "expires_in": {
+                    "description": "天, 0表示不过期",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "read",
+                        "run"
+                    ]
+                }
+            }
+        },
+        "model.ApiTokenRespon": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CredentialForm": {
+            "type": "object",
+            "required": [
+                "cred_id",
+                "kind",
+                "secret"
+            ],
+            "properties": {
+                "cred_id": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "secret",
+                        "username_password",
+                        "ssh_key",
+                        "file"
+                    ]
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.FolderForm": {
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "env": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "labels": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
         "model.LoginForm": {
             "type": "object",
             "required": [
@@ -612,30 +2669,251 @@ const docTemplate = `{
                 "message": {
                     "type": "string"
                 },
+                "refresh_token": {
+                    "description": "访问token过期后用于换取新token",
+                    "type": "string"
+                },
                 "token": {
                     "type": "string"
                 }
             }
         },
+        "model.MoveForm": {
+            "type": "object",
+            "required": [
+                "from",
+                "to"
+            ],
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PoolInfo": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "draining": {
+                    "type": "boolean"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "queued": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.QueuedJobInfo"
+                    }
+                },
+                "running": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.RunningJobInfo"
+                    }
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PoolResizeForm": {
+            "type": "object",
+            "required": [
+                "workers"
+            ],
+            "properties": {
+                "workers": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "model.QueuedJobInfo": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "queued_at": {
+                    "type": "string"
+                },
+                "wait_time": {
+                    "description": "秒",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.RefreshForm": {
+            "type": "object",
+            "required": [
+                "refresh_token"
+            ],
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.RoleBindingForm": {
+            "type": "object",
+            "required": [
+                "role",
+                "user_name"
+            ],
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "maintainer",
+                        "developer",
+                        "viewer"
+                    ]
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "user_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.RunForm": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "branch": {
+                    "type": "string"
+                },
+                "change_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.RunningJobInfo": {
+            "type": "object",
+            "properties": {
+                "elapsed": {
+                    "description": "秒",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.TaskForm": {
             "type": "object",
             "required": [
                 "description",
-                "name",
-                "pipeline"
+                "name"
             ],
             "properties": {
+                "branch": {
+                    "type": "string"
+                },
+                "change_id": {
+                    "type": "string"
+                },
+                "comment": {
+                    "description": "保存时的修订说明",
+                    "type": "string"
+                },
+                "commit": {
+                    "type": "string"
+                },
                 "description": {
                     "type": "string"
                 },
+                "forge": {
+                    "type": "string"
+                },
+                "forge_api": {
+                    "type": "string"
+                },
+                "forge_repo": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "kind": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
+                "params": {
+                    "description": "构建参数, 以环境变量的形式传入步骤",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "pipeline": {
                     "type": "string"
+                },
+                "pipeline_path": {
+                    "type": "string"
+                },
+                "poll_branches": {
+                    "type": "string"
+                },
+                "poll_interval": {
+                    "type": "integer"
+                },
+                "ref": {
+                    "type": "string"
+                },
+                "repo": {
+                    "type": "string"
+                },
+                "trigger_after": {
+                    "type": "string"
+                },
+                "webhook_filter": {
+                    "type": "string"
+                },
+                "webhook_filter_text": {
+                    "type": "string"
+                },
+                "webhook_params": {
+                    "type": "string"
                 }
             }
         },
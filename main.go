@@ -12,6 +12,7 @@ import (
 
 	"gookins/core"
 	"gookins/docs"
+	"gookins/service"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
@@ -40,6 +41,19 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	if err := core.LoadConfig(); err != nil {
+		panic(err)
+	}
+	if err := core.OpenDb(); err != nil {
+		panic(err)
+	}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	if err := core.PrepareDb(); err != nil {
+		panic(err)
+	}
+	core.StartTaskPool()
 	router := newRouter()
 	router.Use(static.Serve("/", static.LocalFile("statics", true)))
 	docs.SwaggerInfo.BasePath = "/"
@@ -49,10 +63,13 @@ func main() {
 		Handler: router,
 	}
 	router.NoRoute(func(ctx *gin.Context) {
-		fmt.Printf("%s doesn't exists, redirect on /\n", ctx.Request.URL.Path)
+		slog.Debug(fmt.Sprintf("%s doesn't exist, serving index.html", ctx.Request.URL.Path))
 		// c.Redirect(http.StatusMovedPermanently, "/")
 		ctx.File("./statics/index.html")
 	})
+	triggerCtx, stopTriggers := context.WithCancel(context.Background())
+	service.StartMultibranchScanner(triggerCtx)
+	service.StartScmPoller(triggerCtx)
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			slog.Error(err.Error())
@@ -62,6 +79,7 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
+	stopTriggers()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
@@ -69,5 +87,6 @@ func main() {
 	} else {
 		slog.Info("服务关闭成功")
 	}
+	core.Tp.Shutdown(time.Duration(core.Config.ShutdownGrace) * time.Second)
 
 }
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// 数据库模型: 审计日志, 只追加不修改, 因此不使用gorm.Model的软删除
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"time"`
+	Actor     string    `gorm:"actor;index" json:"actor"`
+	Action    string    `gorm:"action;index" json:"action"`
+	Target    string    `gorm:"target;index" json:"target"`
+	Ip        string    `gorm:"ip" json:"ip"`
+	Status    int       `gorm:"status" json:"status,omitempty"`
+	// 流水线变更时为统一格式diff
+	Detail string `gorm:"detail;type:text" json:"detail,omitempty"`
+}
+
+// 接口请求模型, 时间为RFC3339格式
+type AuditQuery struct {
+	Actor  string    `form:"actor"`
+	Action string    `form:"action"`
+	Target string    `form:"target"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page   int       `form:"page" binding:"omitempty,min=1"`
+	Size   int       `form:"size" binding:"omitempty,min=1,max=500"`
+}
+
+// 审计日志分页响应
+type AuditPage struct {
+	Total int64      `json:"total"`
+	Items []AuditLog `json:"items"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据库模型: 一次任务运行
+type Build struct {
+	gorm.Model
+	TaskName     string     `gorm:"task_name;index"`
+	PipeLine     string     `gorm:"pipeline;type:text"`
+	Repo         string     `gorm:"repo"`
+	Ref          string     `gorm:"ref"`
+	PipelinePath string     `gorm:"pipeline_path"`
+	Commit       string     `gorm:"commit"`
+	Branch       string     `gorm:"branch"`
+	ChangeId     string     `gorm:"change_id"`
+	Params       string     `gorm:"params;type:text"`
+	Causes       string     `gorm:"causes;type:text"`
+	Revision     int        `gorm:"revision"`
+	State        string     `gorm:"state;index"`
+	Approver     string     `gorm:"approver"`
+	Requeue      bool       `gorm:"requeue;default:false"`
+	ResumeStep   int        `gorm:"resume_step;default:0"`
+	StartedAt    *time.Time `gorm:"started_at"`
+	FinishedAt   *time.Time `gorm:"finished_at"`
+}
+
+// 触发链中的一环: 由哪个任务的哪次构建触发
+type Cause struct {
+	TaskName string `json:"task_name"`
+	BuildId  uint64 `json:"build_id"`
+}
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalTimeout  = "timeout"
+)
+
+// 数据库模型: 流水线中的人工审批
+type Approval struct {
+	gorm.Model
+	BuildId   uint64     `gorm:"build_id;index"`
+	TaskName  string     `gorm:"task_name"`
+	Step      int        `gorm:"step"`
+	StepName  string     `gorm:"step_name"`
+	Message   string     `gorm:"message"`
+	Approvers string     `gorm:"approvers"`
+	State     string     `gorm:"state;index"`
+	DecidedBy string     `gorm:"decided_by"`
+	DecidedAt *time.Time `gorm:"decided_at"`
+	Deadline  *time.Time `gorm:"deadline"`
+}
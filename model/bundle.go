@@ -0,0 +1,98 @@
+package model
+
+import "time"
+
+// 导出文件格式版本, 导入时拒绝更高的版本
+const BundleVersion = 1
+
+const (
+	BundleFormatYaml = "yaml"
+	BundleFormatTar  = "tar"
+)
+
+// 导入时与已有任务同名的处理方式
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+// 导入报告中每一项的处理结果
+const (
+	ImportCreate    = "create"
+	ImportOverwrite = "overwrite"
+	ImportRename    = "rename"
+	ImportSkip      = "skip"
+	ImportError     = "error"
+)
+
+// 任务导出文件. 只包含凭据的引用(id), 不包含凭据的值
+type Bundle struct {
+	Version    int            `yaml:"version"`
+	ExportedAt time.Time      `yaml:"exported_at"`
+	Folders    []BundleFolder `yaml:"folders,omitempty"`
+	Tasks      []BundleTask   `yaml:"tasks,omitempty"`
+}
+
+type BundleFolder struct {
+	Path        string            `yaml:"path"`
+	Description string            `yaml:"description,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	Labels      string            `yaml:"labels,omitempty"`
+}
+
+// 导出的任务, 不包含webhook令牌, 导入后需要重新生成
+type BundleTask struct {
+	Name              string `yaml:"name"`
+	Description       string `yaml:"description,omitempty"`
+	Disabled          bool   `yaml:"disabled,omitempty"`
+	Pipeline          string `yaml:"pipeline,omitempty"`
+	Repo              string `yaml:"repo,omitempty"`
+	Ref               string `yaml:"ref,omitempty"`
+	PipelinePath      string `yaml:"pipeline_path,omitempty"`
+	Kind              string `yaml:"kind,omitempty"`
+	Forge             string `yaml:"forge,omitempty"`
+	ForgeApi          string `yaml:"forge_api,omitempty"`
+	ForgeRepo         string `yaml:"forge_repo,omitempty"`
+	PollBranches      string `yaml:"poll_branches,omitempty"`
+	PollInterval      int    `yaml:"poll_interval,omitempty"`
+	TriggerAfter      string `yaml:"trigger_after,omitempty"`
+	WebhookParams     string `yaml:"webhook_params,omitempty"`
+	WebhookFilter     string `yaml:"webhook_filter,omitempty"`
+	WebhookFilterText string `yaml:"webhook_filter_text,omitempty"`
+	// 流水线引用的凭据id, 目标实例需要有同id的凭据
+	Credentials []string `yaml:"credentials,omitempty"`
+}
+
+// 接口请求模型: 导出单个任务(name)、一个目录(folder)或全部任务(都为空)
+type ExportQuery struct {
+	Name   string `form:"name"`
+	Folder string `form:"folder"`
+	Format string `form:"format" binding:"omitempty,oneof=yaml tar"`
+}
+
+// 接口请求模型: 导入选项
+type ImportQuery struct {
+	DryRun   bool   `form:"dry_run"`
+	Conflict string `form:"conflict" binding:"omitempty,oneof=skip overwrite rename"`
+}
+
+// 导入报告
+type ImportReport struct {
+	DryRun      bool         `json:"dry_run"`
+	Created     int          `json:"created"`
+	Overwritten int          `json:"overwritten"`
+	Renamed     int          `json:"renamed"`
+	Skipped     int          `json:"skipped"`
+	Failed      int          `json:"failed"`
+	Items       []ImportItem `json:"items"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+type ImportItem struct {
+	Kind    string `json:"kind"` // folder或task
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	Target  string `json:"target,omitempty"` // 重命名后的名称
+	Message string `json:"message,omitempty"`
+}
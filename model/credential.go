@@ -0,0 +1,34 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	CredentialSecret           = "secret"
+	CredentialUsernamePassword = "username_password"
+	CredentialSshKey           = "ssh_key"
+	CredentialFile             = "file"
+)
+
+// 数据库模型: 加密存储的凭据, Scope为空时全局可用, 否则对同名任务或该目录下的任务可用
+type Credential struct {
+	gorm.Model
+	CredId      string `gorm:"cred_id;index"`
+	Kind        string `gorm:"kind"`
+	Scope       string `gorm:"scope"`
+	Description string `gorm:"description"`
+	Username    string `gorm:"username"`
+	FileName    string `gorm:"file_name"`
+	Secret      string `gorm:"secret;type:text" json:"-"`
+}
+
+// 接口请求模型
+type CredentialForm struct {
+	Id          string `form:"id"`
+	CredId      string `form:"cred_id" binding:"required"`
+	Kind        string `form:"kind" binding:"required,oneof=secret username_password ssh_key file"`
+	Scope       string `form:"scope"`
+	Description string `form:"description"`
+	Username    string `form:"username"`
+	FileName    string `form:"file_name"`
+	Secret      string `form:"secret" binding:"required"`
+}
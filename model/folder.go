@@ -0,0 +1,27 @@
+package model
+
+import "gorm.io/gorm"
+
+// 数据库模型: 组织任务的目录. 任务名即完整路径, 如team-a/services/api属于目录team-a/services.
+// 目录下的任务继承各级目录的环境变量和执行节点标签, 内层目录覆盖外层
+type Folder struct {
+	gorm.Model
+	Path        string `gorm:"path;uniqueIndex"`
+	Description string `gorm:"description"`
+	Env         string `gorm:"env;type:text"` // JSON对象
+	Labels      string `gorm:"labels"`
+}
+
+// 接口请求模型
+type FolderForm struct {
+	Path        string            `form:"path" binding:"required"`
+	Description string            `form:"description"`
+	Env         map[string]string `form:"env"`
+	Labels      string            `form:"labels"`
+}
+
+// 移动或重命名任务、目录
+type MoveForm struct {
+	From string `form:"from" binding:"required"`
+	To   string `form:"to" binding:"required"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// 数据库模型: 已执行的数据库迁移
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"name"`
+	AppliedAt time.Time `gorm:"applied_at"`
+}
+
+// 迁移状态, AppliedAt为空表示未执行
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据库模型: 任务池运行时状态, 重启后恢复
+type PoolState struct {
+	gorm.Model
+	Paused      bool `gorm:"paused;default:false"`
+	Draining    bool `gorm:"draining;default:false"`
+	WorkerCount int  `gorm:"worker_count"`
+}
+
+// 接口请求模型
+type PoolResizeForm struct {
+	Workers int `form:"workers" binding:"required,min=1"`
+}
+
+// 任务池接口响应模型
+type QueuedJobInfo struct {
+	Id       uint64    `json:"id"`
+	Name     string    `json:"name"`
+	Position int       `json:"position"`
+	QueuedAt time.Time `json:"queued_at"`
+	WaitTime int64     `json:"wait_time"` // 秒
+}
+
+type RunningJobInfo struct {
+	Worker    int       `json:"worker"`
+	Id        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	Elapsed   int64     `json:"elapsed"` // 秒
+}
+
+type PoolInfo struct {
+	Strategy string           `json:"strategy"`
+	Capacity int              `json:"capacity"`
+	Workers  int              `json:"workers"`
+	Paused   bool             `json:"paused"`
+	Draining bool             `json:"draining"`
+	Queued   []QueuedJobInfo  `json:"queued"`
+	Running  []RunningJobInfo `json:"running"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// 数据库模型: 任务流水线的修订版本, 每次保存追加一条, 不可修改
+type TaskRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	TaskName  string    `gorm:"task_name;uniqueIndex:idx_task_revision" json:"task_name"`
+	Revision  int       `gorm:"revision;uniqueIndex:idx_task_revision" json:"revision"`
+	Author    string    `gorm:"author" json:"author"`
+	Comment   string    `gorm:"comment" json:"comment"`
+	PipeLine  string    `gorm:"pipeline;type:text" json:"pipeline,omitempty"`
+}
+
+// 两个修订版本之间的差异
+type RevisionDiff struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	RoleAdmin      = "admin"
+	RoleMaintainer = "maintainer"
+	RoleDeveloper  = "developer"
+	RoleViewer     = "viewer"
+)
+
+// 数据库模型: 用户在某个范围内的角色.
+// Scope为空表示全局, 否则为任务名, 以"*"结尾时匹配该前缀下的所有任务, 如"team-a/*"覆盖目录team-a
+type RoleBinding struct {
+	gorm.Model
+	UserName string `gorm:"user_name;index"`
+	Role     string `gorm:"role"`
+	Scope    string `gorm:"scope"`
+	// 由外部身份源同步的绑定记录来源, 手动创建的为空
+	Source string `gorm:"source"`
+}
+
+// 接口请求模型
+type RoleBindingForm struct {
+	UserName string `form:"user_name" binding:"required"`
+	Role     string `form:"role" binding:"required,oneof=admin maintainer developer viewer"`
+	Scope    string `form:"scope"`
+}
@@ -2,17 +2,90 @@ package model
 
 import "gorm.io/gorm"
 
+const (
+	TaskKindMultibranch = "multibranch"
+	TaskKindBranch      = "branch"
+)
+
 type Task struct {
 	gorm.Model
 	Name        string `gorm:"name"`
 	Description string `gomr:"description"`
 	PipeLine    string `gorm:"pipeline;type:text"`
 	Disabled    bool   `gorm:"disabled;default:false"`
+	// 流水线即代码: 从仓库的指定引用读取流水线文件
+	Repo         string `gorm:"repo"`
+	Ref          string `gorm:"ref"`
+	PipelinePath string `gorm:"pipeline_path"`
+	// 多分支任务: 扫描仓库分支和合并请求, 自动创建子任务
+	Kind      string `gorm:"kind"`
+	ParentId  uint   `gorm:"parent_id;index"`
+	Branch    string `gorm:"branch"`
+	ChangeId  string `gorm:"change_id"`
+	Forge     string `gorm:"forge"`
+	ForgeApi  string `gorm:"forge_api"`
+	ForgeRepo string `gorm:"forge_repo"`
+	// 轮询触发: 按间隔(分钟)检查匹配分支的提交变化
+	PollBranches string `gorm:"poll_branches"`
+	PollInterval int    `gorm:"poll_interval"`
+	// 上游任务(逗号分隔)成功后触发本任务
+	TriggerAfter string `gorm:"trigger_after"`
+	// 通用webhook触发: 参数提取规则(JSON对象, 参数名到"$.path"或"header:Name")和过滤正则
+	WebhookToken      string `gorm:"webhook_token;index" json:"-"`
+	WebhookParams     string `gorm:"webhook_params;type:text"`
+	WebhookFilter     string `gorm:"webhook_filter"`
+	WebhookFilterText string `gorm:"webhook_filter_text"`
+	// 当前流水线对应的修订版本号
+	Revision int `gorm:"revision;default:0"`
+	// 掩码已知凭据后的流水线, 只用于列表展示, 不会写回数据库
+	PipeLineDisplay string `gorm:"-"`
+}
+
+// 轮询触发记录的各分支最后构建的提交
+type PollState struct {
+	gorm.Model
+	TaskId uint   `gorm:"task_id;index"`
+	Branch string `gorm:"branch"`
+	Sha    string `gorm:"sha"`
 }
 
 type TaskForm struct {
-	Id          string `form:"id"`
-	Name        string `form:"name" binding:"required"`
-	Description string `form:"description" binding:"required"`
-	PipeLine    string `form:"pipeline" binding:"required"`
+	Id                string `form:"id"`
+	Name              string `form:"name" binding:"required"`
+	Description       string `form:"description" binding:"required"`
+	PipeLine          string `form:"pipeline" binding:"required_without=Repo"`
+	Repo              string `form:"repo"`
+	Ref               string `form:"ref"`
+	PipelinePath      string `form:"pipeline_path"`
+	Kind              string `form:"kind"`
+	Branch            string `form:"branch"`
+	ChangeId          string `form:"change_id"`
+	Forge             string `form:"forge"`
+	ForgeApi          string `form:"forge_api"`
+	ForgeRepo         string `form:"forge_repo"`
+	Commit            string `form:"commit"`
+	PollBranches      string `form:"poll_branches"`
+	PollInterval      int    `form:"poll_interval"`
+	TriggerAfter      string `form:"trigger_after"`
+	WebhookParams     string `form:"webhook_params"`
+	WebhookFilter     string `form:"webhook_filter"`
+	WebhookFilterText string `form:"webhook_filter_text"`
+	// 保存时的修订说明
+	Comment string `form:"comment"`
+	// 构建参数, 以环境变量的形式传入步骤
+	Params map[string]string `form:"params"`
+	// 触发链, 由任务池在触发下游任务时填写
+	Causes []Cause `json:"-"`
+	// 审批通过后从该步骤继续执行
+	ResumeStep int `json:"-"`
+	// 构建运行的任务修订版本, 由JobFromTask从读取流水线的同一行任务记录填写
+	Revision int `json:"-"`
+}
+
+// 运行任务的请求模型: 只能指定参数和分支或合并请求, 流水线、仓库和提交以保存的任务定义为准
+type RunForm struct {
+	Name     string            `form:"name" json:"name" binding:"required"`
+	Params   map[string]string `form:"params" json:"params"`
+	Branch   string            `form:"branch" json:"branch"`
+	ChangeId string            `form:"change_id" json:"change_id"`
 }
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TokenScopeFull = ""
+	TokenScopeRead = "read"
+	TokenScopeRun  = "run"
+)
+
+// 数据库模型: 个人API令牌, 只保存哈希
+type ApiToken struct {
+	gorm.Model
+	UserName   string     `gorm:"user_name;index"`
+	Name       string     `gorm:"name"`
+	Prefix     string     `gorm:"prefix"`
+	TokenHash  string     `gorm:"token_hash;uniqueIndex" json:"-"`
+	Scope      string     `gorm:"scope"`
+	ExpiresAt  *time.Time `gorm:"expires_at"`
+	LastUsedAt *time.Time `gorm:"last_used_at"`
+}
+
+// 接口请求模型
+type ApiTokenForm struct {
+	Name      string `form:"name" binding:"required"`
+	Scope     string `form:"scope" binding:"omitempty,oneof=read run"`
+	ExpiresIn int    `form:"expires_in" binding:"min=0"` // 天, 0表示不过期
+}
+
+// 接口响应模型: 令牌明文只在创建时返回一次
+type ApiTokenRespon struct {
+	Id    uint   `json:"id"`
+	Token string `json:"token"`
+}
@@ -1,13 +1,38 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // 数据库模型
 type User struct {
 	gorm.Model
+	// 未删除的用户名唯一(迁移0022的部分索引)
 	Name     string `gorm:"name"`
 	Password string `gorm:"password"`
 	Avatar   string `gorm:"avatar"`
+	Disabled bool   `gorm:"disabled;default:false"`
+	// 用户来源: local、ldap或oidc, 外部来源的用户没有本地密码
+	Source string `gorm:"source;default:local"`
+	// 外部身份源中的稳定标识, OIDC为签发者和sub, 用户名可能在身份源中被修改或重复使用
+	Subject string `gorm:"subject;index" json:"-"`
+}
+
+const (
+	UserSourceLocal = "local"
+	UserSourceLdap  = "ldap"
+	UserSourceOidc  = "oidc"
+)
+
+// 登录会话, 保存当前刷新令牌的哈希; 刷新时轮换, PrevHash用于发现被盗用的旧刷新令牌
+type Session struct {
+	gorm.Model
+	UserName    string    `gorm:"user_name;index"`
+	RefreshHash string    `gorm:"refresh_hash;uniqueIndex"`
+	PrevHash    string    `gorm:"prev_hash;index"`
+	ExpiresAt   time.Time `gorm:"expires_at"`
 }
 
 // 接口请求模型
@@ -18,6 +43,10 @@ type UserForm struct {
 	Avatar   string `form:"avatar"`
 }
 
+type RefreshForm struct {
+	RefreshToken string `form:"refresh_token" binding:"required"`
+}
+
 type LoginForm struct {
 	Name     string `form:"name" binding:"required"`
 	Password string `form:"password" binding:"required"`
@@ -28,6 +57,8 @@ type LoginRespon struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Token   string `json:"token"`
+	// 访问token过期后用于换取新token
+	RefreshToken string `json:"refresh_token"`
 }
 
 type ApiRespone struct {
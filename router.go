@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log/slog"
+
 	"gookins/api"
 	"gookins/core"
 
@@ -9,27 +11,97 @@ import (
 
 func newRouter() *gin.Engine {
 	router := gin.New()
+	// 只信任配置的反向代理设置的X-Forwarded-For, 否则客户端可以伪造来源IP绕过登录限制
+	if err := router.SetTrustedProxies(core.Config.TrustedProxies); err != nil {
+		slog.Error(err.Error())
+		router.SetTrustedProxies(nil)
+	}
 
-	router.Use(gin.Logger(), gin.Recovery(), core.CorMiddleware())
+	router.Use(gin.Logger(), gin.Recovery(), core.CorMiddleware(), core.AuditMiddleware())
 
 	router.POST("/login", api.UserSign)
-	userGroup := router.Group("/user", core.AuthMiddleware())
+	router.POST("/refresh", api.RefreshToken)
+	router.POST("/logout", core.AuthMiddleware(), api.UserLogout)
+	router.GET("/oidc/login", api.OidcLogin)
+	router.GET("/oidc/callback", api.OidcCallback)
+	router.POST("/webhook/:token", api.GenericWebhook)
+	userGroup := router.Group("/user", core.AuthMiddleware(), core.PermissionMiddleware(core.PermAdmin, nil))
 	{
 		userGroup.POST("/add", api.CreateUser)
 		userGroup.DELETE("/del/:id", api.DeleteUser)
 		userGroup.PUT("/upt", api.UpdateUser)
+		userGroup.POST("/disable/:id", api.UserDisabled)
+		userGroup.POST("/unlock/:name", api.UnlockUser)
 		userGroup.GET("/list", api.UserLists)
 	}
 	taskGroup := router.Group("/task", core.AuthMiddleware())
 	{
-		taskGroup.POST("/add", api.CreateTask)
-		taskGroup.DELETE("/del/:id", api.DeleteTask)
-		taskGroup.PUT("/upt", api.UpdateTask)
+		taskGroup.POST("/add", core.PermissionMiddleware(core.PermEdit, api.TaskNameBody), api.CreateTask)
+		taskGroup.DELETE("/del/:id", core.PermissionMiddleware(core.PermEdit, api.TaskIdParam), api.DeleteTask)
+		taskGroup.PUT("/upt", core.PermissionMiddleware(core.PermEdit, api.TaskNameBody), api.UpdateTask)
 		taskGroup.GET("/list", api.TaskLists)
-		taskGroup.POST("/run", api.RunTask)
-		taskGroup.POST("/cancel/:name", api.CancelTask)
-		taskGroup.GET("/state/:name", api.TaskStatus)
-		taskGroup.POST("/disable/:name", api.TaskDisabled)
+		taskGroup.GET("/export", api.ExportTasks)
+		taskGroup.POST("/import", api.ImportTasks)
+		taskGroup.POST("/run", core.PermissionMiddleware(core.PermRun, api.TaskNameBody), api.RunTask)
+		taskGroup.POST("/cancel/*name", core.PermissionMiddleware(core.PermCancel, api.TaskNameParam), api.CancelTask)
+		taskGroup.GET("/state/*name", core.PermissionMiddleware(core.PermView, api.TaskNameParam), api.TaskStatus)
+		taskGroup.POST("/disable/*name", core.PermissionMiddleware(core.PermEdit, api.TaskNameParam), api.TaskDisabled)
+		taskGroup.POST("/scan/*name", core.PermissionMiddleware(core.PermEdit, api.TaskNameParam), api.ScanTask)
+		taskGroup.POST("/webhook/*name", core.PermissionMiddleware(core.PermEdit, api.TaskNameParam), api.ResetWebhook)
+		taskGroup.POST("/move", core.PermissionMiddleware(core.PermEdit, api.MoveFromBody), core.PermissionMiddleware(core.PermEdit, api.MoveToBody), api.MoveTask)
+		taskGroup.GET("/revisions/*name", core.PermissionMiddleware(core.PermView, api.TaskNameParam), api.RevisionLists)
+		taskGroup.GET("/revision/:revision/*name", core.PermissionMiddleware(core.PermView, api.TaskNameParam), api.RevisionDetail)
+		taskGroup.GET("/diff/*name", core.PermissionMiddleware(core.PermView, api.TaskNameParam), api.RevisionDiff)
+		taskGroup.POST("/restore/:revision/*name", core.PermissionMiddleware(core.PermEdit, api.TaskNameParam), api.RestoreRevision)
+	}
+	folderGroup := router.Group("/folder", core.AuthMiddleware())
+	{
+		folderGroup.GET("/list", api.FolderLists)
+		folderGroup.POST("/add", core.PermissionMiddleware(core.PermEdit, api.FolderParentBody), api.CreateFolder)
+		folderGroup.PUT("/upt", core.PermissionMiddleware(core.PermEdit, api.FolderPathBody), api.UpdateFolder)
+		folderGroup.DELETE("/del/*path", core.PermissionMiddleware(core.PermEdit, api.FolderPathParam), api.DeleteFolder)
+		folderGroup.POST("/move", core.PermissionMiddleware(core.PermEdit, api.MoveFromFolder), core.PermissionMiddleware(core.PermEdit, api.MoveToFolder), api.MoveFolder)
+	}
+	credentialGroup := router.Group("/credential", core.AuthMiddleware(), core.PermissionMiddleware(core.PermAdmin, nil))
+	{
+		credentialGroup.POST("/add", api.CreateCredential)
+		credentialGroup.DELETE("/del/:id", api.DeleteCredential)
+		credentialGroup.PUT("/upt", api.UpdateCredential)
+		credentialGroup.GET("/list", api.CredentialLists)
+	}
+	approvalGroup := router.Group("/approval", core.AuthMiddleware())
+	{
+		approvalGroup.GET("/list", api.ApprovalLists)
+		approvalGroup.POST("/approve/:id", core.PermissionMiddleware(core.PermApprove, api.ApprovalIdParam), api.ApproveBuild)
+		approvalGroup.POST("/reject/:id", core.PermissionMiddleware(core.PermApprove, api.ApprovalIdParam), api.RejectBuild)
+	}
+	roleGroup := router.Group("/role", core.AuthMiddleware(), core.PermissionMiddleware(core.PermAdmin, nil))
+	{
+		roleGroup.POST("/add", api.CreateRoleBinding)
+		roleGroup.DELETE("/del/:id", api.DeleteRoleBinding)
+		roleGroup.GET("/list", api.RoleBindingLists)
+	}
+	tokenGroup := router.Group("/token", core.AuthMiddleware(), core.FullScopeMiddleware())
+	{
+		tokenGroup.POST("/add", api.CreateApiToken)
+		tokenGroup.DELETE("/del/:id", api.DeleteApiToken)
+		tokenGroup.GET("/list", api.ApiTokenLists)
+	}
+	auditGroup := router.Group("/audit", core.AuthMiddleware(), core.PermissionMiddleware(core.PermAdmin, nil))
+	{
+		auditGroup.GET("/list", api.AuditLists)
+		auditGroup.GET("/export", api.AuditExport)
+	}
+	poolGroup := router.Group("/pool", core.AuthMiddleware())
+	{
+		poolGroup.GET("", api.PoolInfo)
+		poolGroup.GET("/queue", api.PoolQueue)
+		poolGroup.GET("/workers", api.PoolWorkers)
+		poolGroup.DELETE("/queue/:id", core.PermissionMiddleware(core.PermCancel, api.QueuedIdParam), api.RemoveQueued)
+		poolGroup.POST("/pause", core.PermissionMiddleware(core.PermAdmin, nil), api.PausePool)
+		poolGroup.POST("/resume", core.PermissionMiddleware(core.PermAdmin, nil), api.ResumePool)
+		poolGroup.POST("/drain", core.PermissionMiddleware(core.PermAdmin, nil), api.DrainPool)
+		poolGroup.PUT("/resize", core.PermissionMiddleware(core.PermAdmin, nil), api.ResizePool)
 	}
 
 	return router
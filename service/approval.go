@@ -0,0 +1,26 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+var (
+	ErrApprovalLists = errors.New("获取审批列表失败")
+)
+
+func ApprovalLists(state string) ([]model.Approval, error) {
+	var approvals []model.Approval
+	query := core.Db.Model(&model.Approval{}).Order("id desc")
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if result := query.Find(&approvals); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrApprovalLists
+	}
+	return approvals, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+
+	"gookins/core"
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuditLists  = errors.New("获取审计日志失败")
+	ErrAuditExport = errors.New("导出审计日志失败")
+)
+
+const (
+	auditPageSize   = 50
+	auditExportSize = 500
+)
+
+func auditFilter(query model.AuditQuery) *gorm.DB {
+	tx := core.Db.Model(&model.AuditLog{})
+	if query.Actor != "" {
+		tx = tx.Where("actor = ?", query.Actor)
+	}
+	if query.Action != "" {
+		tx = tx.Where("action LIKE ?", "%"+query.Action+"%")
+	}
+	if query.Target != "" {
+		tx = tx.Where("target LIKE ?", "%"+query.Target+"%")
+	}
+	if !query.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		tx = tx.Where("created_at < ?", query.Until)
+	}
+	return tx
+}
+
+// 按条件分页查询审计日志, 最新的在前
+func AuditLists(query model.AuditQuery) (*model.AuditPage, error) {
+	page := model.AuditPage{}
+	if result := auditFilter(query).Count(&page.Total); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrAuditLists
+	}
+	size := query.Size
+	if size == 0 {
+		size = auditPageSize
+	}
+	offset := max(query.Page-1, 0) * size
+	if result := auditFilter(query).Order("id DESC").Offset(offset).Limit(size).Find(&page.Items); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrAuditLists
+	}
+	return &page, nil
+}
+
+// 按时间顺序以JSON lines格式导出, 分批读取避免一次加载全部日志
+func AuditExport(query model.AuditQuery, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	var batch []model.AuditLog
+	result := auditFilter(query).Order("id").FindInBatches(&batch, auditExportSize, func(tx *gorm.DB, n int) error {
+		for _, log := range batch {
+			if err := encoder.Encode(log); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrAuditExport
+	}
+	return nil
+}
@@ -0,0 +1,679 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gookins/core"
+	"gookins/model"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportTasks   = errors.New("导出任务失败")
+	ErrImportTasks   = errors.New("导入任务失败")
+	ErrBundleFormat  = errors.New("导入文件不是有效的YAML或tar.gz")
+	ErrBundleVersion = errors.New("不支持的导入文件版本")
+	ErrBundleSize    = errors.New("导入文件超过32MB")
+
+	// 试运行时用于回滚事务
+	errDryRun = errors.New("dry run")
+)
+
+const (
+	// 导入文件(解压后)的大小上限
+	bundleMaxSize = 32 << 20
+	// tar包中目录清单和任务文件的位置
+	bundleManifest = "bundle.yaml"
+	bundleTaskDir  = "tasks/"
+)
+
+// 导出任务及其所在的各级目录. visible不为nil时只导出它允许的任务和目录(以core.FolderScope检查).
+// 多分支任务的子任务由扫描生成, 不导出. 添加kind列之前创建的任务kind为NULL
+func ExportBundle(query model.ExportQuery, visible func(string) bool) (*model.Bundle, error) {
+	tx := core.Db.Where("(kind IS NULL OR kind <> ?)", model.TaskKindBranch).Order("name")
+	switch {
+	case query.Name != "":
+		tx = tx.Where("name = ?", query.Name)
+	case query.Folder != "":
+		ok, err := folderExists(core.Db, query.Folder)
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, ErrExportTasks
+		}
+		if !ok {
+			return nil, ErrFolderNotFound
+		}
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, likePrefix(query.Folder+"/"))
+	}
+	var tasks []model.Task
+	if result := tx.Find(&tasks); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrExportTasks
+	}
+	if query.Name != "" && len(tasks) == 0 {
+		return nil, ErrTaskNotFound
+	}
+
+	// 导出任务的各级上级目录, 导出目录时还包括其下的所有子目录
+	paths := map[string]bool{}
+	addParents := func(name string) {
+		for p := core.ParentPath(name); p != ""; p = core.ParentPath(p) {
+			paths[p] = true
+		}
+	}
+	for _, task := range tasks {
+		addParents(task.Name)
+	}
+	var folders []model.Folder
+	ftx := core.Db.Order("path")
+	switch {
+	case query.Name != "":
+		ftx = ftx.Where("path IN ?", mapKeys(paths))
+	case query.Folder != "":
+		addParents(query.Folder)
+		paths[query.Folder] = true
+		ftx = ftx.Where(`path IN ? OR path LIKE ? ESCAPE '\'`, mapKeys(paths), likePrefix(query.Folder+"/"))
+	}
+	if query.Name == "" || len(paths) > 0 {
+		if result := ftx.Find(&folders); result.Error != nil {
+			slog.Error(result.Error.Error())
+			return nil, ErrExportTasks
+		}
+	}
+
+	bundle := model.Bundle{Version: model.BundleVersion, ExportedAt: time.Now().UTC()}
+	for _, folder := range folders {
+		if visible == nil || visible(core.FolderScope(folder.Path)) {
+			bundle.Folders = append(bundle.Folders, bundleFolder(folder))
+		}
+	}
+	secrets := core.LoadSecrets()
+	for _, task := range tasks {
+		if visible == nil || visible(task.Name) {
+			bundle.Tasks = append(bundle.Tasks, bundleTask(task, secrets))
+		}
+	}
+	return &bundle, nil
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func bundleFolder(folder model.Folder) model.BundleFolder {
+	bf := model.BundleFolder{Path: folder.Path, Description: folder.Description, Labels: folder.Labels}
+	if folder.Env != "" {
+		if err := json.Unmarshal([]byte(folder.Env), &bf.Env); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+	return bf
+}
+
+// 流水线中直接粘贴的凭据值被掩码, 只保留凭据引用
+func bundleTask(task model.Task, secrets *core.SecretCache) model.BundleTask {
+	pipeline := secrets.Mask(task.Name, task.PipeLine)
+	return model.BundleTask{
+		Name:              task.Name,
+		Description:       task.Description,
+		Disabled:          task.Disabled,
+		Pipeline:          pipeline,
+		Repo:              task.Repo,
+		Ref:               task.Ref,
+		PipelinePath:      task.PipelinePath,
+		Kind:              task.Kind,
+		Forge:             task.Forge,
+		ForgeApi:          task.ForgeApi,
+		ForgeRepo:         task.ForgeRepo,
+		PollBranches:      task.PollBranches,
+		PollInterval:      task.PollInterval,
+		TriggerAfter:      task.TriggerAfter,
+		WebhookParams:     task.WebhookParams,
+		WebhookFilter:     task.WebhookFilter,
+		WebhookFilterText: task.WebhookFilterText,
+		Credentials:       core.PipelineCredentials(pipeline),
+	}
+}
+
+// 写出导出文件: yaml为单个YAML文档; tar为tar.gz, 包含目录清单bundle.yaml和每个任务一个tasks/<任务名>.yaml
+func WriteBundle(w io.Writer, bundle *model.Bundle, format string) error {
+	if format != model.BundleFormatTar {
+		return encodeYaml(w, bundle)
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	manifest := *bundle
+	manifest.Tasks = nil
+	if err := writeTarYaml(tw, bundleManifest, bundle.ExportedAt, &manifest); err != nil {
+		return err
+	}
+	for i := range bundle.Tasks {
+		name := bundleTaskDir + bundle.Tasks[i].Name + ".yaml"
+		if err := writeTarYaml(tw, name, bundle.ExportedAt, &bundle.Tasks[i]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func encodeYaml(w io.Writer, v any) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func writeTarYaml(tw *tar.Writer, name string, modTime time.Time, v any) error {
+	var buf bytes.Buffer
+	if err := encodeYaml(&buf, v); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(buf.Len()), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(buf.Bytes())
+	return err
+}
+
+// 读取导入文件, 按gzip文件头自动识别YAML或tar.gz格式
+func ReadBundle(r io.Reader) (*model.Bundle, error) {
+	data, err := io.ReadAll(io.LimitReader(r, bundleMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > bundleMaxSize {
+		return nil, ErrBundleSize
+	}
+	var bundle model.Bundle
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		if err := readTarBundle(data, &bundle); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.Unmarshal(data, &bundle); err != nil {
+		slog.Error(err.Error())
+		return nil, ErrBundleFormat
+	}
+	if bundle.Version == 0 {
+		return nil, ErrBundleFormat
+	}
+	if bundle.Version > model.BundleVersion {
+		return nil, fmt.Errorf("%w: %d", ErrBundleVersion, bundle.Version)
+	}
+	return &bundle, nil
+}
+
+func readTarBundle(data []byte, bundle *model.Bundle) error {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return ErrBundleFormat
+	}
+	tr := tar.NewReader(gr)
+	var tasks []model.BundleTask
+	// 限制解压后的总大小
+	remaining := int64(bundleMaxSize)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrBundleFormat
+		}
+		name := path.Clean(header.Name)
+		isTask := strings.HasPrefix(name, bundleTaskDir) && strings.HasSuffix(name, ".yaml")
+		if header.Typeflag != tar.TypeReg || (name != bundleManifest && !isTask) {
+			continue
+		}
+		if header.Size > remaining {
+			return ErrBundleSize
+		}
+		remaining -= header.Size
+		content, err := io.ReadAll(io.LimitReader(tr, header.Size))
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrBundleFormat
+		}
+		if name == bundleManifest {
+			if err := yaml.Unmarshal(content, bundle); err != nil {
+				slog.Error(err.Error())
+				return ErrBundleFormat
+			}
+			continue
+		}
+		var task model.BundleTask
+		if err := yaml.Unmarshal(content, &task); err != nil {
+			slog.Error(err.Error())
+			return fmt.Errorf("%w: %s", ErrBundleFormat, name)
+		}
+		tasks = append(tasks, task)
+	}
+	bundle.Tasks = append(bundle.Tasks, tasks...)
+	return nil
+}
+
+// 导入任务和目录, 整个导入在一个事务中完成; 试运行时执行同样的检查和写入后回滚.
+// allowed不为nil时检查每个任务(任务名)和目录(core.FolderScope)的编辑权限.
+// 单个任务或目录的问题记录在报告中, 不影响其他项
+func ImportBundle(bundle *model.Bundle, query model.ImportQuery, author string, allowed, runnable func(string) bool) (*model.ImportReport, error) {
+	im := &bundleImporter{
+		report:   &model.ImportReport{DryRun: query.DryRun, Items: []model.ImportItem{}},
+		conflict: query.Conflict,
+		author:   author,
+		allowed:  allowed,
+		runnable: runnable,
+		folders:  map[string]bool{},
+	}
+	if im.conflict == "" {
+		im.conflict = model.ConflictSkip
+	}
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		im.tx = tx
+		// 按路径排序使上级目录先于子目录处理
+		folders := append([]model.BundleFolder(nil), bundle.Folders...)
+		sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+		for _, folder := range folders {
+			if err := im.importFolder(folder); err != nil {
+				return err
+			}
+		}
+		if err := im.importTasks(bundle.Tasks); err != nil {
+			return err
+		}
+		if query.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		slog.Error(err.Error())
+		return nil, ErrImportTasks
+	}
+	return im.report, nil
+}
+
+// 导入报告的一行摘要
+func ImportSummary(report *model.ImportReport) string {
+	summary := fmt.Sprintf("创建%d, 覆盖%d, 重命名%d, 跳过%d, 失败%d",
+		report.Created, report.Overwritten, report.Renamed, report.Skipped, report.Failed)
+	if report.DryRun {
+		summary = "试运行: " + summary
+	}
+	return summary
+}
+
+type bundleImporter struct {
+	tx       *gorm.DB
+	report   *model.ImportReport
+	conflict string
+	author   string
+	allowed  func(string) bool
+	// 流水线trigger步骤的目标任务需要运行权限
+	runnable func(string) bool
+	// 已确认存在(或本次导入创建)的目录
+	folders map[string]bool
+}
+
+func (im *bundleImporter) can(name string) bool {
+	return im.allowed == nil || im.allowed(name)
+}
+
+func (im *bundleImporter) add(item model.ImportItem) {
+	switch item.Action {
+	case model.ImportCreate:
+		im.report.Created++
+	case model.ImportOverwrite:
+		im.report.Overwritten++
+	case model.ImportRename:
+		im.report.Renamed++
+	case model.ImportSkip:
+		im.report.Skipped++
+	case model.ImportError:
+		im.report.Failed++
+	}
+	im.report.Items = append(im.report.Items, item)
+}
+
+func (im *bundleImporter) warn(format string, args ...any) {
+	im.report.Warnings = append(im.report.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (im *bundleImporter) taskExists(name string) (bool, error) {
+	var count int64
+	result := im.tx.Model(&model.Task{}).Where("name = ?", name).Count(&count)
+	return count > 0, result.Error
+}
+
+// 确保p的各级上级目录存在, 缺少的自动创建. 不能创建时返回原因
+func (im *bundleImporter) ensureParents(p string) (string, error) {
+	parent := core.ParentPath(p)
+	if parent == "" || im.folders[parent] {
+		return "", nil
+	}
+	if reason, err := im.ensureParents(parent); reason != "" || err != nil {
+		return reason, err
+	}
+	ok, err := folderExists(im.tx, parent)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		taken, err := im.taskExists(parent)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return fmt.Sprintf("上级目录%s已被同名任务占用", parent), nil
+		}
+		if !im.can(core.FolderScope(core.ParentPath(parent))) {
+			return fmt.Sprintf("没有创建目录%s的权限", parent), nil
+		}
+		if result := im.tx.Create(&model.Folder{Path: parent}); result.Error != nil {
+			return "", result.Error
+		}
+		im.add(model.ImportItem{Kind: "folder", Name: parent, Action: model.ImportCreate, Message: "自动创建上级目录"})
+	}
+	im.folders[parent] = true
+	return "", nil
+}
+
+// 已存在的目录只在overwrite时更新设置, 否则沿用
+func (im *bundleImporter) importFolder(folder model.BundleFolder) error {
+	item := model.ImportItem{Kind: "folder", Name: folder.Path}
+	fail := func(message string) error {
+		item.Action, item.Message = model.ImportError, message
+		im.add(item)
+		return nil
+	}
+	if err := core.ValidatePath(folder.Path); err != nil {
+		return fail(err.Error())
+	}
+	reason, err := im.ensureParents(folder.Path)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fail(reason)
+	}
+	exists, err := folderExists(im.tx, folder.Path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case exists && im.conflict != model.ConflictOverwrite:
+		item.Action, item.Message = model.ImportSkip, "目录已存在"
+	case exists:
+		if !im.can(core.FolderScope(folder.Path)) {
+			return fail("没有权限")
+		}
+		updates := map[string]any{"description": folder.Description, "env": marshalEnv(folder.Env), "labels": folder.Labels}
+		if result := im.tx.Model(&model.Folder{}).Where("path = ?", folder.Path).Updates(updates); result.Error != nil {
+			return result.Error
+		}
+		item.Action = model.ImportOverwrite
+	default:
+		taken, err := im.taskExists(folder.Path)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fail(ErrFolderExists.Error())
+		}
+		if !im.can(core.FolderScope(core.ParentPath(folder.Path))) {
+			return fail("没有权限")
+		}
+		dbFolder := model.Folder{Path: folder.Path, Description: folder.Description, Env: marshalEnv(folder.Env), Labels: folder.Labels}
+		if result := im.tx.Create(&dbFolder); result.Error != nil {
+			return result.Error
+		}
+		item.Action = model.ImportCreate
+	}
+	im.folders[folder.Path] = true
+	im.add(item)
+	return nil
+}
+
+// 先确定每个任务的处理方式和目标名称, 再写入, 以便把上游任务列表中被重命名的任务替换为新名称
+func (im *bundleImporter) importTasks(tasks []model.BundleTask) error {
+	items := make([]model.ImportItem, len(tasks))
+	renamed := map[string]string{}
+	planned := map[string]bool{}
+	for i, task := range tasks {
+		item, err := im.planTask(task, planned)
+		if err != nil {
+			return err
+		}
+		if item.Action == model.ImportRename {
+			renamed[task.Name] = item.Target
+		}
+		items[i] = item
+	}
+	for i, task := range tasks {
+		item := items[i]
+		switch item.Action {
+		case model.ImportSkip, model.ImportError:
+			im.add(item)
+			continue
+		}
+		target := task.Name
+		if item.Target != "" {
+			target = item.Target
+		}
+		task.TriggerAfter = renameTriggers(task.TriggerAfter, renamed)
+		if err := checkTriggerCycle(im.tx, target, task.TriggerAfter); err != nil {
+			item.Action, item.Message = model.ImportError, err.Error()
+			im.add(item)
+			continue
+		}
+		if err := im.writeTask(target, task, item.Action == model.ImportOverwrite); err != nil {
+			return err
+		}
+		im.add(item)
+		for _, id := range core.PipelineCredentials(task.Pipeline) {
+			if !core.CredentialExists(im.tx, target, id) {
+				im.warn("任务%s引用的凭据%s不存在", target, id)
+			}
+		}
+		if strings.Contains(task.Pipeline, "****") {
+			im.warn("任务%s的流水线包含导出时掩码的秘密值, 请改为引用凭据", target)
+		}
+		if task.WebhookParams != "" || task.WebhookFilter != "" {
+			im.warn("任务%s配置了webhook触发, 需要重新生成webhook令牌", target)
+		}
+	}
+	return nil
+}
+
+func (im *bundleImporter) planTask(task model.BundleTask, planned map[string]bool) (model.ImportItem, error) {
+	item := model.ImportItem{Kind: "task", Name: task.Name}
+	fail := func(message string) (model.ImportItem, error) {
+		item.Action, item.Message = model.ImportError, message
+		return item, nil
+	}
+	if err := core.ValidatePath(task.Name); err != nil {
+		return fail(err.Error())
+	}
+	if planned[task.Name] {
+		return fail("导入文件中任务重复")
+	}
+	if task.Kind == model.TaskKindBranch {
+		return fail("多分支任务的子任务由扫描自动创建, 不能导入")
+	}
+	if task.Kind == model.TaskKindMultibranch && task.Repo == "" {
+		return fail(ErrMultibranchRepo.Error())
+	}
+	if task.Repo == "" {
+		if err := core.ValidatePipeline(task.Pipeline); err != nil {
+			return fail(err.Error())
+		}
+		if err := CheckTriggerTargets(task.Pipeline, im.runnable); err != nil {
+			return fail(err.Error())
+		}
+	}
+	if err := checkWebhookConfig(model.TaskForm{WebhookParams: task.WebhookParams, WebhookFilter: task.WebhookFilter}); err != nil {
+		return fail(err.Error())
+	}
+	planned[task.Name] = true
+
+	exists, err := im.taskExists(task.Name)
+	if err != nil {
+		return item, err
+	}
+	target := task.Name
+	switch {
+	case exists && im.conflict == model.ConflictSkip:
+		item.Action, item.Message = model.ImportSkip, "任务已存在"
+		return item, nil
+	case exists && im.conflict == model.ConflictOverwrite:
+		if !im.can(task.Name) {
+			return fail("没有权限")
+		}
+		item.Action = model.ImportOverwrite
+		return item, nil
+	case exists:
+		target, err = im.freeName(task.Name, planned)
+		if err != nil {
+			return item, err
+		}
+		item.Action, item.Target = model.ImportRename, target
+		planned[target] = true
+	default:
+		item.Action = model.ImportCreate
+	}
+	if !im.can(target) {
+		return fail("没有权限")
+	}
+	reason, err := im.ensureParents(target)
+	if err != nil {
+		return item, err
+	}
+	if reason != "" {
+		return fail(reason)
+	}
+	if taken, err := folderExists(im.tx, target); err != nil {
+		return item, err
+	} else if taken {
+		return fail(ErrFolderExists.Error())
+	}
+	return item, nil
+}
+
+// 重命名时的新名称: name-imported, name-imported-2, ...
+func (im *bundleImporter) freeName(name string, planned map[string]bool) (string, error) {
+	for i := 1; ; i++ {
+		candidate := name + "-imported"
+		if i > 1 {
+			candidate += fmt.Sprint("-", i)
+		}
+		if planned[candidate] {
+			continue
+		}
+		taken, err := pathTaken(im.tx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+}
+
+// 把上游任务列表中被重命名的任务替换为新名称
+func renameTriggers(triggerAfter string, renamed map[string]string) string {
+	if triggerAfter == "" || len(renamed) == 0 {
+		return triggerAfter
+	}
+	names := strings.Split(triggerAfter, ",")
+	for i, name := range names {
+		if target, ok := renamed[strings.TrimSpace(name)]; ok {
+			names[i] = target
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// 创建或覆盖任务并追加修订版本
+func (im *bundleImporter) writeTask(name string, task model.BundleTask, overwrite bool) error {
+	if !overwrite {
+		dbTask := model.Task{
+			Name:              name,
+			Description:       task.Description,
+			PipeLine:          task.Pipeline,
+			Disabled:          task.Disabled,
+			Repo:              task.Repo,
+			Ref:               task.Ref,
+			PipelinePath:      task.PipelinePath,
+			Kind:              task.Kind,
+			Forge:             task.Forge,
+			ForgeApi:          task.ForgeApi,
+			ForgeRepo:         task.ForgeRepo,
+			PollBranches:      task.PollBranches,
+			PollInterval:      task.PollInterval,
+			TriggerAfter:      task.TriggerAfter,
+			WebhookParams:     task.WebhookParams,
+			WebhookFilter:     task.WebhookFilter,
+			WebhookFilterText: task.WebhookFilterText,
+		}
+		if result := im.tx.Create(&dbTask); result.Error != nil {
+			return result.Error
+		}
+		_, err := addRevision(im.tx, name, im.author, "导入", task.Pipeline)
+		return err
+	}
+	var dbTask model.Task
+	if result := im.tx.Where("name = ?", name).Limit(1).Find(&dbTask); result.Error != nil {
+		return result.Error
+	}
+	if dbTask.Revision == 0 && dbTask.PipeLine != "" {
+		if _, err := addRevision(im.tx, name, "", "修订版本记录之前的流水线", dbTask.PipeLine); err != nil {
+			return err
+		}
+	}
+	updates := map[string]any{
+		"description":         task.Description,
+		"pipe_line":           task.Pipeline,
+		"disabled":            task.Disabled,
+		"repo":                task.Repo,
+		"ref":                 task.Ref,
+		"pipeline_path":       task.PipelinePath,
+		"kind":                task.Kind,
+		"forge":               task.Forge,
+		"forge_api":           task.ForgeApi,
+		"forge_repo":          task.ForgeRepo,
+		"poll_branches":       task.PollBranches,
+		"poll_interval":       task.PollInterval,
+		"trigger_after":       task.TriggerAfter,
+		"webhook_params":      task.WebhookParams,
+		"webhook_filter":      task.WebhookFilter,
+		"webhook_filter_text": task.WebhookFilterText,
+	}
+	if result := im.tx.Model(&model.Task{}).Where("name = ?", name).Updates(updates); result.Error != nil {
+		return result.Error
+	}
+	_, err := addRevision(im.tx, name, im.author, "导入覆盖", task.Pipeline)
+	return err
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 与接口一样在导入前读取权限, 凭据检查在导入事务中使用事务的tx
+func TestImportBundle(t *testing.T) {
+	coretest.OpenDb(t)
+	if err := core.Db.Create(&model.RoleBinding{UserName: "alice", Role: model.RoleMaintainer, Scope: "app*"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateFolder(model.FolderForm{Path: "app"}); err != nil {
+		t.Fatal(err)
+	}
+	mustCreateTask(t, "app-old", testPipeline)
+	bundle := &model.Bundle{
+		Folders: []model.BundleFolder{{Path: "app/sub"}},
+		Tasks: []model.BundleTask{
+			{Name: "app/build", Pipeline: "steps:\n  - name: build\n    command: make\n    credentials:\n      - id: missing\n        env: TOKEN\n"},
+			{Name: "app-old", Pipeline: testPipeline},
+			{Name: "ops", Pipeline: testPipeline},
+		},
+	}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set(core.UsernameKey, "alice")
+	allowed := core.Checker(ctx, core.PermEdit)
+	report, err := ImportBundle(bundle, model.ImportQuery{Conflict: model.ConflictOverwrite}, "alice", allowed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 2 || report.Overwritten != 1 || report.Failed != 1 {
+		t.Fatalf("导入报告为%s: %+v", ImportSummary(report), report.Items)
+	}
+	if len(report.Warnings) != 1 {
+		t.Fatalf("警告为%v", report.Warnings)
+	}
+	if task := taskByName(t, "app/build"); task.Revision != 1 {
+		t.Fatalf("导入的任务版本为%d", task.Revision)
+	}
+
+	report, err = ImportBundle(bundle, model.ImportQuery{DryRun: true, Conflict: model.ConflictRename}, "alice", allowed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Renamed != 2 || report.Skipped != 1 {
+		t.Fatalf("试运行报告为%s", ImportSummary(report))
+	}
+	var count int64
+	core.Db.Model(&model.Task{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("试运行后任务数为%d", count)
+	}
+}
+
+// 添加kind列之前创建的任务kind为NULL, 也要导出; 多分支子任务不导出
+func TestExportBundleLegacyKind(t *testing.T) {
+	coretest.OpenDb(t)
+	mustCreateTask(t, "legacy", testPipeline)
+	mustCreateTask(t, "app", testPipeline)
+	if err := core.Db.Exec("UPDATE tasks SET kind = NULL WHERE name = ?", "legacy").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := core.Db.Create(&model.Task{Name: "app-main", Kind: model.TaskKindBranch}).Error; err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ExportBundle(model.ExportQuery{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Tasks) != 2 || bundle.Tasks[0].Name != "app" || bundle.Tasks[1].Name != "legacy" {
+		t.Fatalf("导出的任务为%+v", bundle.Tasks)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+var (
+	ErrCreateCredential = errors.New("创建凭据失败")
+	ErrDeleteCredential = errors.New("删除凭据失败")
+	ErrUpdateCredential = errors.New("更新凭据失败")
+	ErrCredentialLists  = errors.New("获取凭据列表失败")
+	ErrCredentialExists = errors.New("相同范围内凭据ID已存在")
+)
+
+func CreateCredential(credential model.CredentialForm) error {
+	var count int64
+	if result := core.Db.Model(&model.Credential{}).Where("cred_id = ? AND scope = ?", credential.CredId, credential.Scope).Count(&count); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrCreateCredential
+	}
+	if count > 0 {
+		return ErrCredentialExists
+	}
+	secret, err := core.EncryptSecret(credential.Secret)
+	if err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	dbCredential := model.Credential{
+		CredId:      credential.CredId,
+		Kind:        credential.Kind,
+		Scope:       credential.Scope,
+		Description: credential.Description,
+		Username:    credential.Username,
+		FileName:    credential.FileName,
+		Secret:      secret,
+	}
+	if result := core.Db.Create(&dbCredential); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrCreateCredential
+	}
+	return nil
+}
+
+func DeleteCredential(id uint64) error {
+	if result := core.Db.Where("id = ?", id).Delete(&model.Credential{}); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrDeleteCredential
+	}
+	return nil
+}
+
+func UpdateCredential(credential model.CredentialForm) error {
+	secret, err := core.EncryptSecret(credential.Secret)
+	if err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	updates := map[string]any{
+		"cred_id":     credential.CredId,
+		"kind":        credential.Kind,
+		"scope":       credential.Scope,
+		"description": credential.Description,
+		"username":    credential.Username,
+		"file_name":   credential.FileName,
+		"secret":      secret,
+	}
+	if result := core.Db.Model(&model.Credential{}).Where("id = ?", credential.Id).Updates(updates); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrUpdateCredential
+	}
+	return nil
+}
+
+// 凭据列表不返回加密后的内容
+func CredentialLists() ([]model.Credential, error) {
+	var credentials []model.Credential
+	result := core.Db.Model(&model.Credential{}).Select("id, created_at, updated_at, cred_id, kind, scope, description, username, file_name").Find(&credentials)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrCredentialLists
+	}
+	return credentials, nil
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"gookins/core"
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFolderNotFound = errors.New("目录不存在")
+	ErrFolderExists   = errors.New("同名的任务或目录已存在")
+	ErrFolderNotEmpty = errors.New("目录不为空")
+	ErrFolderBusy     = errors.New("有构建正在排队或运行, 不能移动")
+	ErrMoveIntoSelf   = errors.New("不能将目录移动到自身或其子目录下")
+	ErrCreateFolder   = errors.New("创建目录失败")
+	ErrUpdateFolder   = errors.New("更新目录失败")
+	ErrDeleteFolder   = errors.New("删除目录失败")
+	ErrFolderLists    = errors.New("获取目录列表失败")
+	ErrMove           = errors.New("移动失败")
+)
+
+// LIKE前缀匹配, 转义通配符
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+func folderExists(tx *gorm.DB, path string) (bool, error) {
+	var count int64
+	result := tx.Model(&model.Folder{}).Where("path = ?", path).Count(&count)
+	return count > 0, result.Error
+}
+
+// 路径是否已被任务或目录占用
+func pathTaken(tx *gorm.DB, path string) (bool, error) {
+	if ok, err := folderExists(tx, path); err != nil || ok {
+		return ok, err
+	}
+	var count int64
+	result := tx.Model(&model.Task{}).Where("name = ?", path).Count(&count)
+	return count > 0, result.Error
+}
+
+// 新任务或目录的路径: 格式合法, 上级目录存在且路径未被占用
+func checkNewPath(tx *gorm.DB, path string) error {
+	if err := core.ValidatePath(path); err != nil {
+		return err
+	}
+	if parent := core.ParentPath(path); parent != "" {
+		ok, err := folderExists(tx, parent)
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrFolderLists
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFolderNotFound, parent)
+		}
+	}
+	taken, err := pathTaken(tx, path)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrFolderLists
+	}
+	if taken {
+		return ErrFolderExists
+	}
+	return nil
+}
+
+func marshalEnv(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(env)
+	return string(data)
+}
+
+func CreateFolder(form model.FolderForm) error {
+	if err := checkNewPath(core.Db, form.Path); err != nil {
+		return err
+	}
+	folder := model.Folder{Path: form.Path, Description: form.Description, Env: marshalEnv(form.Env), Labels: form.Labels}
+	if result := core.Db.Create(&folder); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrCreateFolder
+	}
+	return nil
+}
+
+func UpdateFolder(form model.FolderForm) error {
+	updates := map[string]any{"description": form.Description, "env": marshalEnv(form.Env), "labels": form.Labels}
+	result := core.Db.Model(&model.Folder{}).Where("path = ?", form.Path).Updates(updates)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrUpdateFolder
+	}
+	if result.RowsAffected == 0 {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// 只能删除空目录; 硬删除以便之后重新创建同名目录
+func DeleteFolder(path string) error {
+	var count int64
+	if result := core.Db.Model(&model.Folder{}).Where(`path LIKE ? ESCAPE '\'`, likePrefix(path+"/")).Count(&count); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrDeleteFolder
+	}
+	if count == 0 {
+		if result := core.Db.Model(&model.Task{}).Where(`name LIKE ? ESCAPE '\'`, likePrefix(path+"/")).Count(&count); result.Error != nil {
+			slog.Error(result.Error.Error())
+			return ErrDeleteFolder
+		}
+	}
+	if count > 0 {
+		return ErrFolderNotEmpty
+	}
+	result := core.Db.Unscoped().Where("path = ?", path).Delete(&model.Folder{})
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrDeleteFolder
+	}
+	if result.RowsAffected == 0 {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// parent目录下的直接子目录, parent为空时列出顶层目录
+func FolderLists(parent string) ([]model.Folder, error) {
+	var folders []model.Folder
+	tx := core.Db.Order("path")
+	if parent == "" {
+		tx = tx.Where("path NOT LIKE ?", "%/%")
+	} else {
+		tx = tx.Where(`path LIKE ? ESCAPE '\'`, likePrefix(parent+"/")).Where(`path NOT LIKE ? ESCAPE '\'`, likePrefix(parent+"/")+"/%")
+	}
+	if result := tx.Find(&folders); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrFolderLists
+	}
+	return folders, nil
+}
+
+// 名称为old或(prefix时)以old/开头的构建是否在排队、运行或等待审批
+func movingBusy(tx *gorm.DB, old string, prefix bool) (bool, error) {
+	query := tx.Model(&model.Build{}).Where("state IN ?", []string{core.TaskPending, core.TaskRunning, core.TaskWaiting})
+	if prefix {
+		query = query.Where(`task_name = ? OR task_name LIKE ? ESCAPE '\'`, old, likePrefix(old+"/"))
+	} else {
+		query = query.Where("task_name = ?", old)
+	}
+	var count int64
+	result := query.Count(&count)
+	return count > 0, result.Error
+}
+
+// 将表中某列等于old的值改为new; prefix时同时将以old/开头的值替换为new/开头
+func renameColumn(tx *gorm.DB, table, column, old, new string, prefix bool) error {
+	if result := tx.Table(table).Where(column+" = ?", old).Update(column, new); result.Error != nil {
+		return result.Error
+	}
+	if !prefix {
+		return nil
+	}
+	// SUBSTR按字符计数, 任务名可能包含中文
+	rest := utf8.RuneCountInString(old) + 2
+	result := tx.Table(table).Where(column+` LIKE ? ESCAPE '\'`, likePrefix(old+"/")).
+		Update(column, gorm.Expr("? || SUBSTR("+column+", ?)", new+"/", rest))
+	return result.Error
+}
+
+// 重命名任务名及所有按任务名引用它的记录
+func renameReferences(tx *gorm.DB, old, new string, prefix bool) error {
+	columns := [][2]string{
+		{"tasks", "name"},
+		{"folders", "path"},
+		{"builds", "task_name"},
+		{"task_revisions", "task_name"},
+		{"approvals", "task_name"},
+		{"role_bindings", "scope"},
+		{"credentials", "scope"},
+	}
+	for _, c := range columns {
+		if err := renameColumn(tx, c[0], c[1], old, new, prefix); err != nil {
+			return err
+		}
+	}
+	// 下游任务的上游列表
+	var tasks []model.Task
+	if result := tx.Select("id, trigger_after").Where("trigger_after <> ''").Find(&tasks); result.Error != nil {
+		return result.Error
+	}
+	for _, task := range tasks {
+		names := strings.Split(task.TriggerAfter, ",")
+		changed := false
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			switch {
+			case name == old:
+				names[i], changed = new, true
+			case prefix && strings.HasPrefix(name, old+"/"):
+				names[i], changed = new+strings.TrimPrefix(name, old), true
+			}
+		}
+		if changed {
+			if result := tx.Model(&task).Update("trigger_after", strings.Join(names, ",")); result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+	return renameTriggerSteps(tx, old, new, prefix)
+}
+
+// 改写其他任务流水线中trigger步骤的目标, 并记录新的修订版本.
+// 从仓库读取的流水线不在这里保存, 需要在仓库中修改
+func renameTriggerSteps(tx *gorm.DB, old, new string, prefix bool) error {
+	var tasks []model.Task
+	result := tx.Select("id, name, pipe_line, revision").Where(`pipe_line LIKE ? ESCAPE '\'`, "%"+likePrefix(old)).Find(&tasks)
+	if result.Error != nil {
+		return result.Error
+	}
+	for _, task := range tasks {
+		pipeline, changed := core.RenameTriggerTargets(task.PipeLine, old, new, prefix)
+		if !changed {
+			continue
+		}
+		// 引入修订版本之前创建的任务, 先保留原流水线作为第一个版本
+		if task.Revision == 0 {
+			if _, err := addRevision(tx, task.Name, "", "修订版本记录之前的流水线", task.PipeLine); err != nil {
+				return err
+			}
+		}
+		if result := tx.Model(&task).Update("pipe_line", pipeline); result.Error != nil {
+			return result.Error
+		}
+		if _, err := addRevision(tx, task.Name, "", fmt.Sprintf("%s移动到%s, 更新trigger目标", old, new), pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkMoveTarget(tx *gorm.DB, old string, prefix bool, to string) error {
+	if err := checkNewPath(tx, to); err != nil {
+		return err
+	}
+	busy, err := movingBusy(tx, old, prefix)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ErrFolderBusy
+	}
+	return nil
+}
+
+// 移动或重命名目录, 目录下的子目录和任务随之移动
+func MoveFolder(from, to string) error {
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return ErrMoveIntoSelf
+	}
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		ok, err := folderExists(tx, from)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrFolderNotFound
+		}
+		if err := checkMoveTarget(tx, from, true, to); err != nil {
+			return err
+		}
+		return renameReferences(tx, from, to, true)
+	})
+	return moveError(err)
+}
+
+// 移动或重命名任务; 多分支任务的子任务随之改名
+func MoveTask(from, to string) error {
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		var task model.Task
+		result := tx.Where("name = ?", from).Limit(1).Find(&task)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskNotFound
+		}
+		if err := checkMoveTarget(tx, from, false, to); err != nil {
+			return err
+		}
+		if err := renameReferences(tx, from, to, false); err != nil {
+			return err
+		}
+		if task.Kind != model.TaskKindMultibranch {
+			return nil
+		}
+		var children []model.Task
+		if result := tx.Where("parent_id = ?", task.ID).Find(&children); result.Error != nil {
+			return result.Error
+		}
+		for _, child := range children {
+			childTo := to + strings.TrimPrefix(child.Name, from)
+			if err := checkMoveTarget(tx, child.Name, false, childTo); err != nil {
+				return err
+			}
+			if err := renameReferences(tx, child.Name, childTo, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return moveError(err)
+}
+
+// 业务错误原样返回, 数据库错误记录日志后统一返回
+func moveError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, known := range []error{ErrFolderNotFound, ErrFolderExists, ErrFolderBusy, ErrTaskNotFound, core.ErrInvalidPath} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	slog.Error(err.Error())
+	return ErrMove
+}
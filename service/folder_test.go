@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+func TestMoveFolder(t *testing.T) {
+	coretest.OpenDb(t)
+	for _, path := range []string{"app", "app/sub", "ops"} {
+		if err := CreateFolder(model.FolderForm{Path: path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustCreateTask(t, "app/build", testPipeline)
+	mustCreateTask(t, "app/sub/test", testPipeline)
+	if err := UpdateTask(model.TaskForm{Name: "ops/deploy", PipeLine: testPipeline}, "bob"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	mustCreateTask(t, "ops/deploy", testPipeline)
+	mustCreateTask(t, "ops/release", "steps:\n  - name: build\n    trigger:\n      task: app/sub/test\n")
+	if err := UpdateTask(model.TaskForm{Name: "ops/deploy", PipeLine: testPipeline, TriggerAfter: "app/build"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MoveFolder("app", "app/sub/app"); !errors.Is(err, ErrMoveIntoSelf) {
+		t.Fatalf("移动到自身下 err = %v", err)
+	}
+	if err := MoveFolder("app", "ops"); !errors.Is(err, ErrFolderExists) {
+		t.Fatalf("移动到已存在的路径 err = %v", err)
+	}
+	if err := MoveFolder("app", "web"); err != nil {
+		t.Fatal(err)
+	}
+	taskByName(t, "web/build")
+	taskByName(t, "web/sub/test")
+	if task := taskByName(t, "ops/deploy"); task.TriggerAfter != "web/build" {
+		t.Fatalf("上游触发规则为%q", task.TriggerAfter)
+	}
+	// 其他任务流水线中的trigger目标随之改写并记录新版本
+	if task := taskByName(t, "ops/release"); task.Revision != 2 || task.PipeLine != "steps:\n  - name: build\n    trigger:\n      task: web/sub/test\n" {
+		t.Fatalf("trigger目标改写后版本为%d, 流水线为%q", task.Revision, task.PipeLine)
+	}
+	folders, err := FolderLists("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 1 || folders[0].Path != "web/sub" {
+		t.Fatalf("子目录为%+v", folders)
+	}
+}
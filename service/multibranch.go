@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gookins/core"
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+// 分支子任务的名称, 分支名中的"/"替换为"~"使子任务与父任务在同一目录下.
+// git分支名不能包含"~", 所以不同分支的子任务名称不会相同
+func childTaskName(parent, branch string) string {
+	return fmt.Sprintf("%s-%s", parent, strings.ReplaceAll(branch, "/", "~"))
+}
+
+// 合并请求子任务的名称, 以"~"分隔, 不会与分支子任务重名
+func changeTaskName(parent, id string) string {
+	return fmt.Sprintf("%s~PR-%s", parent, id)
+}
+
+// 正在扫描的多分支任务. 同一任务的扫描并发执行时会重复创建或误删子任务,
+// 手动扫描与定时扫描重叠时后来的扫描直接返回
+var scanning sync.Map
+
+// 扫描多分支任务的仓库分支和合并请求, 为包含流水线文件的分支创建子任务, 删除已不存在的分支对应的子任务
+func ScanMultibranch(ctx context.Context, name string) error {
+	if _, busy := scanning.LoadOrStore(name, struct{}{}); busy {
+		return ErrScanRunning
+	}
+	defer scanning.Delete(name)
+	var parent model.Task
+	result := core.Db.Where("name = ? AND kind = ?", name, model.TaskKindMultibranch).Limit(1).Find(&parent)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrScanMultibranch
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskNotFound
+	}
+	path := parent.PipelinePath
+	if path == "" {
+		path = core.DefaultPipelinePath
+	}
+	scanDir := core.WorkspaceDir(parent.Name + ".scan")
+
+	wanted := make(map[string]model.Task)
+	branches, err := core.ListBranches(ctx, parent.Repo)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrScanMultibranch
+	}
+	// 检查失败时不能确定分支是否还需要子任务, 中止扫描以免误删
+	for branch := range branches {
+		ref := "refs/heads/" + branch
+		ok, err := core.HasPipelineFile(ctx, scanDir, parent.Repo, ref, path)
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrScanMultibranch
+		}
+		if ok {
+			childName := childTaskName(parent.Name, branch)
+			wanted[childName] = model.Task{Name: childName, Ref: ref, Branch: branch}
+		}
+	}
+	if parent.Forge != "" {
+		changes, err := core.ListChanges(ctx, parent.Name, parent.Forge, parent.ForgeApi, parent.ForgeRepo)
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrScanMultibranch
+		}
+		for _, change := range changes {
+			ok, err := core.HasPipelineFile(ctx, scanDir, parent.Repo, change.Ref, path)
+			if err != nil {
+				slog.Error(err.Error())
+				return ErrScanMultibranch
+			}
+			if ok {
+				childName := changeTaskName(parent.Name, change.Id)
+				wanted[childName] = model.Task{Name: childName, Ref: change.Ref, Branch: change.Branch, ChangeId: change.Id}
+			}
+		}
+	}
+
+	var children []model.Task
+	if result := core.Db.Where("parent_id = ?", parent.ID).Find(&children); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrScanMultibranch
+	}
+	for _, child := range children {
+		if _, ok := wanted[child.Name]; ok {
+			delete(wanted, child.Name)
+			continue
+		}
+		var waiting []uint64
+		err := core.Db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			waiting, err = deleteTasks(tx, []model.Task{child})
+			return err
+		})
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrScanMultibranch
+		}
+		for _, buildId := range waiting {
+			core.RemoveBuildWorkspace(buildId)
+		}
+		slog.Info(fmt.Sprintf("Multibranch %s removed %s", parent.Name, child.Name))
+	}
+	for _, child := range wanted {
+		child.Description = fmt.Sprintf("%s: %s", parent.Name, child.Branch)
+		child.Repo = parent.Repo
+		child.PipelinePath = path
+		child.Kind = model.TaskKindBranch
+		child.ParentId = parent.ID
+		if err := createChildTask(&child); err != nil {
+			// 名称已被其他任务或目录占用时跳过该分支, 不影响其他子任务
+			if errors.Is(err, ErrFolderExists) || errors.Is(err, ErrFolderNotFound) || errors.Is(err, core.ErrInvalidPath) {
+				slog.Warn(fmt.Sprintf("Multibranch %s skipped %s: %v", parent.Name, child.Name, err))
+				continue
+			}
+			slog.Error(err.Error())
+			return ErrScanMultibranch
+		}
+		slog.Info(fmt.Sprintf("Multibranch %s created %s", parent.Name, child.Name))
+	}
+	return nil
+}
+
+// 与CreateTask一样检查路径并记录第一个修订版本
+func createChildTask(child *model.Task) error {
+	return core.Db.Transaction(func(tx *gorm.DB) error {
+		if err := checkNewPath(tx, child.Name); err != nil {
+			return err
+		}
+		if result := tx.Create(child); result.Error != nil {
+			return result.Error
+		}
+		_, err := addRevision(tx, child.Name, "", "多分支扫描创建", "")
+		return err
+	})
+}
+
+// 按配置的间隔定期扫描所有多分支任务
+func StartMultibranchScanner(ctx context.Context) {
+	if core.Config.ScanInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(core.Config.ScanInterval) * time.Minute)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				var tasks []model.Task
+				if result := core.Db.Where("kind = ?", model.TaskKindMultibranch).Find(&tasks); result.Error != nil {
+					slog.Error(result.Error.Error())
+					continue
+				}
+				for _, task := range tasks {
+					if err := ScanMultibranch(ctx, task.Name); err != nil {
+						slog.Error(fmt.Sprintf("scan %s: %v", task.Name, err))
+					}
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+func TestChildTaskNameInjective(t *testing.T) {
+	names := map[string]string{}
+	for _, branch := range []string{"feature/x", "feature-x", "PR-1", "PR/1"} {
+		names[childTaskName("app", branch)] = branch
+	}
+	names[changeTaskName("app", "1")] = "PR 1"
+	if len(names) != 5 {
+		t.Fatalf("子任务重名: %v", names)
+	}
+}
+
+func TestCreateChildTask(t *testing.T) {
+	coretest.OpenDb(t)
+	mustCreateTask(t, "app-main", testPipeline)
+	taken := model.Task{Name: "app-main", Kind: model.TaskKindBranch}
+	if err := createChildTask(&taken); err != ErrFolderExists {
+		t.Fatalf("重名时 err = %v", err)
+	}
+	child := model.Task{Name: childTaskName("app", "feature/x"), Kind: model.TaskKindBranch, Repo: "https://example.com/app.git"}
+	if err := createChildTask(&child); err != nil {
+		t.Fatal(err)
+	}
+	if task := taskByName(t, child.Name); task.Revision != 1 {
+		t.Fatalf("子任务版本为%d", task.Revision)
+	}
+}
+
+func TestScanMultibranchRunning(t *testing.T) {
+	scanning.Store("app", struct{}{})
+	defer scanning.Delete("app")
+	if err := ScanMultibranch(context.Background(), "app"); err != ErrScanRunning {
+		t.Fatalf("扫描进行中时 err = %v", err)
+	}
+}
+
+// 删除多分支任务时一起删除子任务, 删除后可以重新使用任务名
+func TestDeleteMultibranchChildren(t *testing.T) {
+	coretest.OpenDb(t)
+	parent := model.Task{Name: "app", Kind: model.TaskKindMultibranch, Repo: "https://example.com/app.git"}
+	if err := core.Db.Create(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+	child := model.Task{Name: changeTaskName("app", "1"), Kind: model.TaskKindBranch, ParentId: parent.ID}
+	if err := createChildTask(&child); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.Db.Create(&model.Task{Name: child.Name}).Error; err == nil {
+		t.Fatal("同名任务不应创建成功")
+	}
+	if err := DeleteTask(uint64(parent.ID)); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	core.Db.Model(&model.Task{}).Where("name IN ?", []string{parent.Name, child.Name}).Count(&count)
+	if count != 0 {
+		t.Fatalf("删除后还有%d个任务", count)
+	}
+	if err := createChildTask(&model.Task{Name: child.Name, Kind: model.TaskKindBranch}); err != nil {
+		t.Fatalf("删除后重新创建子任务: %v", err)
+	}
+}
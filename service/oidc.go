@@ -0,0 +1,11 @@
+package service
+
+import (
+	"gookins/core"
+	"gookins/model"
+)
+
+// OIDC登录成功后按签发者和sub查找或创建用户, 并按IdP组同步角色绑定, 返回系统中的用户名
+func OidcSign(identity *core.OidcIdentity) (string, error) {
+	return provisionExternalUser(identity.Username, model.UserSourceOidc, identity.Subject, core.MapGroupRoles(core.Config.Oidc.GroupRoles, identity.Groups))
+}
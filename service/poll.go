@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+// 轮询调度检查间隔
+const pollTick = 30 * time.Second
+
+// 分支是否匹配逗号分隔的通配符模式, 未配置时匹配所有分支
+func matchBranch(patterns, branch string) bool {
+	if patterns == "" {
+		return true
+	}
+	for _, pattern := range strings.Split(patterns, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 在[0, interval/10)内随机抖动, 避免大量任务同时轮询
+func pollJitter(interval time.Duration) time.Duration {
+	if interval < 10*time.Second {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(interval / 10)))
+}
+
+// 轮询任务仓库, 匹配分支的提交变化时加入任务池.
+// 首次观察到的分支只记录提交, 避免启用轮询时集中触发构建
+func PollTask(ctx context.Context, task model.Task) error {
+	branches, err := core.ListBranches(ctx, task.Repo)
+	if err != nil {
+		return err
+	}
+	var states []model.PollState
+	if result := core.Db.Where("task_id = ?", task.ID).Find(&states); result.Error != nil {
+		return result.Error
+	}
+	known := make(map[string]*model.PollState, len(states))
+	for i := range states {
+		known[states[i].Branch] = &states[i]
+	}
+	for branch, sha := range branches {
+		if !matchBranch(task.PollBranches, branch) {
+			continue
+		}
+		state, ok := known[branch]
+		if !ok {
+			state := model.PollState{TaskId: task.ID, Branch: branch, Sha: sha}
+			if result := core.Db.Create(&state); result.Error != nil {
+				return result.Error
+			}
+			continue
+		}
+		if state.Sha == sha {
+			continue
+		}
+		job := core.JobFromTask(&task)
+		job.Ref, job.Branch, job.Commit = "refs/heads/"+branch, branch, sha
+		buildId, err := core.Tp.AddTask(job)
+		if err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Poll %s: branch %s changed to %s, build: %d", task.Name, branch, sha, buildId))
+		if result := core.Db.Model(state).Update("sha", sha); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// 需要轮询的任务. 多分支任务本身不构建, 由扫描维护的子任务各自轮询.
+// 添加kind列之前创建的任务kind为NULL
+func pollTasks() ([]model.Task, error) {
+	var tasks []model.Task
+	result := core.Db.Where("poll_interval > 0 AND repo <> '' AND disabled = ? AND (kind IS NULL OR kind <> ?)", false, model.TaskKindMultibranch).Find(&tasks)
+	return tasks, result.Error
+}
+
+// 启动轮询触发器, 每个任务按自己的间隔加随机抖动轮询
+func StartScmPoller(ctx context.Context) {
+	var mu sync.Mutex
+	nextPoll := make(map[uint]time.Time)
+	polling := make(map[uint]bool)
+	go func() {
+		ticker := time.NewTicker(pollTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			tasks, err := pollTasks()
+			if err != nil {
+				slog.Error(err.Error())
+				continue
+			}
+			now := time.Now()
+			for _, task := range tasks {
+				interval := time.Duration(task.PollInterval) * time.Minute
+				mu.Lock()
+				next, ok := nextPoll[task.ID]
+				if !ok {
+					// 首次调度在一个间隔内随机分布
+					nextPoll[task.ID] = now.Add(time.Duration(rand.Int64N(int64(interval))))
+					mu.Unlock()
+					continue
+				}
+				if now.Before(next) || polling[task.ID] {
+					mu.Unlock()
+					continue
+				}
+				polling[task.ID] = true
+				nextPoll[task.ID] = now.Add(interval + pollJitter(interval))
+				mu.Unlock()
+				go func(task model.Task) {
+					if err := PollTask(ctx, task); err != nil {
+						slog.Error(fmt.Sprintf("poll %s: %v", task.Name, err))
+					}
+					mu.Lock()
+					delete(polling, task.ID)
+					mu.Unlock()
+				}(task)
+			}
+		}
+	}()
+}
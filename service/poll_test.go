@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+func TestPollJitter(t *testing.T) {
+	if jitter := pollJitter(5 * time.Second); jitter != 0 {
+		t.Fatalf("间隔小于10秒时抖动为%v", jitter)
+	}
+	for i := 0; i < 100; i++ {
+		if jitter := pollJitter(time.Minute); jitter < 0 || jitter >= 6*time.Second {
+			t.Fatalf("抖动%v超出范围", jitter)
+		}
+	}
+}
+
+func TestPollTasksSkipMultibranch(t *testing.T) {
+	coretest.OpenDb(t)
+	for _, task := range []model.Task{
+		{Name: "app", Repo: "https://example.com/app.git", PollInterval: 1},
+		{Name: "multi", Repo: "https://example.com/app.git", PollInterval: 1, Kind: model.TaskKindMultibranch},
+		{Name: "multi-main", Repo: "https://example.com/app.git", PollInterval: 1, Kind: model.TaskKindBranch},
+	} {
+		if err := core.Db.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks, err := pollTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	if len(names) != 2 || names[0] != "app" || names[1] != "multi-main" {
+		t.Fatalf("轮询的任务为%v", names)
+	}
+}
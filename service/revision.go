@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"gookins/core"
+	"gookins/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRevisionNotFound = errors.New("修订版本不存在")
+	ErrRevisionLists    = errors.New("获取修订版本失败")
+	ErrRestoreRevision  = errors.New("恢复修订版本失败")
+)
+
+// 在事务中为任务追加修订版本并更新任务的当前版本号, 返回新版本号.
+// (task_name, revision)唯一, 并发保存时后提交的事务失败
+func addRevision(tx *gorm.DB, name, author, comment, pipeline string) (int, error) {
+	var latest int
+	result := tx.Model(&model.TaskRevision{}).Where("task_name = ?", name).Select("COALESCE(MAX(revision), 0)").Scan(&latest)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	revision := model.TaskRevision{TaskName: name, Revision: latest + 1, Author: author, Comment: comment, PipeLine: pipeline}
+	if result := tx.Create(&revision); result.Error != nil {
+		return 0, result.Error
+	}
+	if result := tx.Model(&model.Task{}).Where("name = ?", name).Update("revision", revision.Revision); result.Error != nil {
+		return 0, result.Error
+	}
+	return revision.Revision, nil
+}
+
+// 任务的修订版本列表, 最新的在前, 不包含流水线内容
+func RevisionLists(name string) ([]model.TaskRevision, error) {
+	var revisions []model.TaskRevision
+	result := core.Db.Select("id, created_at, task_name, revision, author, comment").Where("task_name = ?", name).Order("revision DESC").Find(&revisions)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrRevisionLists
+	}
+	return revisions, nil
+}
+
+func getRevision(name string, revision int) (*model.TaskRevision, error) {
+	var rev model.TaskRevision
+	result := core.Db.Where("task_name = ? AND revision = ?", name, revision).Limit(1).Find(&rev)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrRevisionLists
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRevisionNotFound
+	}
+	return &rev, nil
+}
+
+// 单个修订版本, 流水线中的已知凭据被掩码
+func RevisionDetail(name string, revision int) (*model.TaskRevision, error) {
+	rev, err := getRevision(name, revision)
+	if err != nil {
+		return nil, err
+	}
+	rev.PipeLine = core.MaskSecrets(name, rev.PipeLine)
+	return rev, nil
+}
+
+// 两个修订版本之间的diff, to为0时与任务当前版本比较
+func DiffRevisions(name string, from, to int) (*model.RevisionDiff, error) {
+	if to == 0 {
+		var task model.Task
+		core.Db.Select("revision").Where("name = ?", name).Limit(1).Find(&task)
+		to = task.Revision
+	}
+	fromRev, err := getRevision(name, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := getRevision(name, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := core.UnifiedDiff(fmt.Sprintf("%s@%d", name, from), fmt.Sprintf("%s@%d", name, to), fromRev.PipeLine, toRev.PipeLine)
+	return &model.RevisionDiff{From: from, To: to, Diff: core.MaskSecrets(name, diff)}, nil
+}
+
+// 将任务的流水线恢复为旧版本, 恢复本身也记录为一个新版本; 返回恢复前后的流水线用于审计
+func RestoreRevision(name string, revision int, author string, runnable func(string) bool) (string, string, error) {
+	rev, err := getRevision(name, revision)
+	if err != nil {
+		return "", "", err
+	}
+	var task model.Task
+	result := core.Db.Where("name = ?", name).Limit(1).Find(&task)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return "", "", ErrTaskNotFound
+	}
+	if task.Repo == "" {
+		if err := core.ValidatePipeline(rev.PipeLine); err != nil {
+			return "", "", err
+		}
+		if err := CheckTriggerTargets(rev.PipeLine, runnable); err != nil {
+			return "", "", err
+		}
+	}
+	err = core.Db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Model(&model.Task{}).Where("name = ?", name).Update("pipe_line", rev.PipeLine); result.Error != nil {
+			return result.Error
+		}
+		_, err := addRevision(tx, name, author, fmt.Sprintf("恢复到版本%d", revision), rev.PipeLine)
+		return err
+	})
+	if err != nil {
+		slog.Error(err.Error())
+		return "", "", ErrRestoreRevision
+	}
+	return task.PipeLine, rev.PipeLine, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+var (
+	ErrCreateRole = errors.New("创建角色绑定失败")
+	ErrDeleteRole = errors.New("删除角色绑定失败")
+	ErrRoleLists  = errors.New("获取角色绑定列表失败")
+)
+
+func CreateRoleBinding(binding model.RoleBindingForm) error {
+	var count int64
+	if result := core.Db.Model(&model.User{}).Where("name = ?", binding.UserName).Count(&count); result.Error != nil || count == 0 {
+		return ErrUserNotFound
+	}
+	dbBinding := model.RoleBinding{UserName: binding.UserName, Role: binding.Role, Scope: binding.Scope}
+	if result := core.Db.Create(&dbBinding); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrCreateRole
+	}
+	return nil
+}
+
+func DeleteRoleBinding(id uint64) error {
+	if result := core.Db.Where("id = ?", id).Delete(&model.RoleBinding{}); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrDeleteRole
+	}
+	return nil
+}
+
+func RoleBindingLists() ([]model.RoleBinding, error) {
+	var bindings []model.RoleBinding
+	if result := core.Db.Order("user_name, id").Find(&bindings); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrRoleLists
+	}
+	return bindings, nil
+}
+
+// 按id查找任务名, 供权限检查使用
+func TaskNameById(id string) string {
+	var task model.Task
+	core.Db.Unscoped().Select("name").Where("id = ?", id).Limit(1).Find(&task)
+	return task.Name
+}
+
+// 按id查找审批对应的任务名, 供权限检查使用
+func ApprovalTaskName(id string) string {
+	var approval model.Approval
+	core.Db.Select("task_name").Where("id = ?", id).Limit(1).Find(&approval)
+	return approval.TaskName
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"gookins/core"
 	"gookins/model"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -12,45 +16,272 @@ var (
 	ErrDeleteTask = errors.New("删除任务失败")
 	ErrUpdateTask = errors.New("更新任务失败")
 	ErrTaskLists  = errors.New("获取任务列表失败")
+
+	ErrTaskNotFound    = errors.New("任务不存在")
+	ErrMultibranchRepo = errors.New("多分支任务必须指定仓库")
+	ErrScanMultibranch = errors.New("扫描多分支任务失败")
+	ErrScanRunning     = errors.New("多分支任务正在扫描")
+	ErrTriggerCycle    = errors.New("上游触发规则形成环")
+	ErrWebhookConfig   = errors.New("webhook参数提取规则或过滤正则无效")
+	ErrRunMultibranch  = errors.New("多分支任务不能直接运行")
+	ErrRunBranch       = errors.New("只有未指定分支的仓库任务可以选择分支或合并请求")
+	ErrRunChange       = errors.New("任务未配置代码托管平台, 不能运行合并请求")
 )
 
-func CreateTask(task model.TaskForm) error {
-	fmt.Println(task.Name, task.Description, task.PipeLine)
+func CreateTask(task model.TaskForm, author string) error {
+	if err := checkNewPath(core.Db, task.Name); err != nil {
+		return err
+	}
+	if task.Kind == model.TaskKindMultibranch && task.Repo == "" {
+		return ErrMultibranchRepo
+	}
+	if task.Repo == "" {
+		if err := core.ValidatePipeline(task.PipeLine); err != nil {
+			return err
+		}
+	}
+	if err := checkTriggerCycle(core.Db, task.Name, task.TriggerAfter); err != nil {
+		return err
+	}
+	if err := checkWebhookConfig(task); err != nil {
+		return err
+	}
 	dbTask := model.Task{
-		Name:        task.Name,
-		Description: task.Description,
-		PipeLine:    task.PipeLine,
+		Name:              task.Name,
+		Description:       task.Description,
+		PipeLine:          task.PipeLine,
+		Repo:              task.Repo,
+		Ref:               task.Ref,
+		PipelinePath:      task.PipelinePath,
+		Kind:              task.Kind,
+		Forge:             task.Forge,
+		ForgeApi:          task.ForgeApi,
+		ForgeRepo:         task.ForgeRepo,
+		PollBranches:      task.PollBranches,
+		PollInterval:      task.PollInterval,
+		TriggerAfter:      task.TriggerAfter,
+		WebhookParams:     task.WebhookParams,
+		WebhookFilter:     task.WebhookFilter,
+		WebhookFilterText: task.WebhookFilterText,
 	}
-	result := core.Db.Create(&dbTask)
-	if result.Error != nil {
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Create(&dbTask); result.Error != nil {
+			return result.Error
+		}
+		_, err := addRevision(tx, task.Name, author, task.Comment, task.PipeLine)
+		return err
+	})
+	if err != nil {
+		slog.Error(err.Error())
 		return ErrCreateTask
 	}
 	return nil
 }
 
+// 删除任务, 多分支任务的分支和合并请求子任务一起删除
 func DeleteTask(id uint64) error {
-	var task model.Task
-	result := core.Db.Where("id = ?", id).Delete(&task)
-	if result.Error != nil {
-		return ErrDeleteUser
+	var waiting []uint64
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		var tasks []model.Task
+		if result := tx.Where("id = ? OR parent_id = ?", id, id).Find(&tasks); result.Error != nil {
+			return result.Error
+		}
+		if len(tasks) == 0 {
+			return ErrTaskNotFound
+		}
+		var err error
+		waiting, err = deleteTasks(tx, tasks)
+		return err
+	})
+	if errors.Is(err, ErrTaskNotFound) {
+		return err
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrDeleteTask
+	}
+	for _, buildId := range waiting {
+		core.RemoveBuildWorkspace(buildId)
 	}
 	return nil
 }
 
-func UpdateTask(task model.TaskForm) error {
-	result := core.Db.Model(&model.Task{}).Where("name = ?", task.Name).Updates(model.Task{Name: task.Name, Description: task.Description, PipeLine: task.PipeLine})
-	if result.Error != nil {
+// 在事务中删除任务及按任务名或ID关联的数据: 任务范围的角色绑定和凭据、修订版本、轮询状态和待审批记录,
+// 同名任务重新创建时不会继承这些数据. 等待审批的构建标记为cancelled, 返回它们的ID以便提交后删除工作目录
+func deleteTasks(tx *gorm.DB, tasks []model.Task) ([]uint64, error) {
+	names := make([]string, 0, len(tasks))
+	ids := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		names = append(names, task.Name)
+		ids = append(ids, task.ID)
+	}
+	if result := tx.Where("scope IN ?", names).Delete(&model.RoleBinding{}); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := tx.Where("scope IN ?", names).Delete(&model.Credential{}); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := tx.Where("task_name IN ?", names).Delete(&model.TaskRevision{}); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := tx.Where("task_id IN ?", ids).Delete(&model.PollState{}); result.Error != nil {
+		return nil, result.Error
+	}
+	var waiting []uint64
+	pending := tx.Model(&model.Approval{}).Where("task_name IN ? AND state = ?", names, model.ApprovalPending)
+	if result := pending.Pluck("build_id", &waiting); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := tx.Where("task_name IN ? AND state = ?", names, model.ApprovalPending).Delete(&model.Approval{}); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(waiting) > 0 {
+		updates := map[string]any{"state": core.TaskCancelled, "finished_at": time.Now()}
+		if result := tx.Model(&model.Build{}).Where("id IN ? AND state = ?", waiting, core.TaskWaiting).Updates(updates); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	if result := tx.Where("id IN ?", ids).Delete(&model.Task{}); result.Error != nil {
+		return nil, result.Error
+	}
+	return waiting, nil
+}
+
+func UpdateTask(task model.TaskForm, author string) error {
+	if task.Repo == "" {
+		if err := core.ValidatePipeline(task.PipeLine); err != nil {
+			return err
+		}
+	}
+	if err := checkTriggerCycle(core.Db, task.Name, task.TriggerAfter); err != nil {
+		return err
+	}
+	if err := checkWebhookConfig(task); err != nil {
+		return err
+	}
+	updates := map[string]any{
+		"name":                task.Name,
+		"description":         task.Description,
+		"pipe_line":           task.PipeLine,
+		"repo":                task.Repo,
+		"ref":                 task.Ref,
+		"pipeline_path":       task.PipelinePath,
+		"forge":               task.Forge,
+		"forge_api":           task.ForgeApi,
+		"forge_repo":          task.ForgeRepo,
+		"poll_branches":       task.PollBranches,
+		"poll_interval":       task.PollInterval,
+		"trigger_after":       task.TriggerAfter,
+		"webhook_params":      task.WebhookParams,
+		"webhook_filter":      task.WebhookFilter,
+		"webhook_filter_text": task.WebhookFilterText,
+	}
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		var dbTask model.Task
+		if result := tx.Where("name = ?", task.Name).Limit(1).Find(&dbTask); result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return ErrTaskNotFound
+		}
+		// 引入修订版本之前创建的任务, 先保留原流水线作为第一个版本
+		if dbTask.Revision == 0 && dbTask.PipeLine != "" {
+			if _, err := addRevision(tx, task.Name, "", "修订版本记录之前的流水线", dbTask.PipeLine); err != nil {
+				return err
+			}
+		}
+		if result := tx.Model(&model.Task{}).Where("name = ?", task.Name).Updates(updates); result.Error != nil {
+			return result.Error
+		}
+		// 只修改描述、触发规则等设置时流水线不变, 不产生新的修订版本
+		if task.PipeLine == dbTask.PipeLine {
+			return nil
+		}
+		_, err := addRevision(tx, task.Name, author, task.Comment, task.PipeLine)
+		return err
+	})
+	if errors.Is(err, ErrTaskNotFound) {
+		return err
+	}
+	if err != nil {
+		slog.Error(err.Error())
 		return ErrUpdateTask
 	}
 	return nil
 }
 
+// 流水线trigger步骤触发的任务需要保存者有运行权限, 避免借助流水线运行无权运行的任务.
+// runnable为nil时不检查, 用于命令行
+func CheckTriggerTargets(pipeline string, runnable func(string) bool) error {
+	if runnable == nil {
+		return nil
+	}
+	for _, name := range core.PipelineTriggers(pipeline) {
+		if !runnable(name) {
+			return fmt.Errorf("%w: 触发任务%s", core.ErrPermissionDenied, name)
+		}
+	}
+	return nil
+}
+
+// 按保存的任务定义生成要运行的构建, 请求只能指定参数.
+// 分支和合并请求只对普通仓库任务有效, 多分支的子任务固定构建自己的分支
+func RunJob(name string, params map[string]string, branch, changeId string) (*core.TaskJob, error) {
+	var task model.Task
+	result := core.Db.Where("name = ?", name).Limit(1).Find(&task)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrTaskNotFound
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTaskNotFound
+	}
+	if task.Kind == model.TaskKindMultibranch {
+		return nil, ErrRunMultibranch
+	}
+	if task.Disabled {
+		return nil, ErrTaskDisabled
+	}
+	if err := core.ValidateParams(params); err != nil {
+		return nil, err
+	}
+	job := core.JobFromTask(&task)
+	job.Params = params
+	if branch == "" && changeId == "" {
+		return job, nil
+	}
+	if task.Kind != "" || task.Repo == "" || (branch != "" && changeId != "") {
+		return nil, ErrRunBranch
+	}
+	if branch != "" {
+		job.Ref, job.Branch = "refs/heads/"+branch, branch
+		return job, nil
+	}
+	ref := core.ChangeRef(task.Forge, changeId)
+	if ref == "" {
+		return nil, ErrRunChange
+	}
+	job.Ref, job.ChangeId = ref, changeId
+	return job, nil
+}
+
+// 任务当前的流水线定义, 任务不存在时为空
+func TaskPipeline(name string) string {
+	var task model.Task
+	core.Db.Select("pipe_line").Where("name = ?", name).Limit(1).Find(&task)
+	return task.PipeLine
+}
+
 func TaskLists() ([]model.Task, error) {
 	var tasks []model.Task
-	result := core.Db.Unscoped().Model(&model.Task{}).Select("id, created_at, updated_at, deleted_at, name, description, pipe_line").Find(&tasks)
+	result := core.Db.Unscoped().Model(&model.Task{}).Select("id, created_at, updated_at, deleted_at, name, description, pipe_line, repo, ref, pipeline_path, kind, parent_id, branch, change_id, forge, forge_api, forge_repo, poll_branches, poll_interval, trigger_after, webhook_params, webhook_filter, webhook_filter_text").Find(&tasks)
 	if result.Error != nil {
 		return nil, ErrTaskLists
 	}
+	// 流水线中直接粘贴的凭据只在展示字段中掩码, PipeLine保持原文以便编辑后保存
+	secrets := core.LoadSecrets()
+	for i := range tasks {
+		tasks[i].PipeLineDisplay = secrets.Mask(tasks[i].Name, tasks[i].PipeLine)
+	}
 	return tasks, nil
 }
 
@@ -61,3 +292,31 @@ func TaskDisable(name string, disable bool) error {
 	}
 	return nil
 }
+
+// 检查将name的上游设置为triggerAfter后是否形成环:
+// 若某个上游任务是name的(间接)下游, 则拒绝
+func checkTriggerCycle(tx *gorm.DB, name, triggerAfter string) error {
+	if triggerAfter == "" {
+		return nil
+	}
+	var tasks []model.Task
+	if result := tx.Select("name, trigger_after").Where("trigger_after <> '' AND name <> ?", name).Find(&tasks); result.Error != nil {
+		return ErrUpdateTask
+	}
+	visited := map[string]bool{name: true}
+	pending := []string{name}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if core.ContainsTaskName(triggerAfter, current) {
+			return ErrTriggerCycle
+		}
+		for _, task := range tasks {
+			if !visited[task.Name] && core.ContainsTaskName(task.TriggerAfter, current) {
+				visited[task.Name] = true
+				pending = append(pending, task.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+const testPipeline = "steps:\n  - name: build\n    command: make\n"
+
+func mustCreateTask(t *testing.T, name, pipeline string) {
+	t.Helper()
+	if err := CreateTask(model.TaskForm{Name: name, PipeLine: pipeline}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func taskByName(t *testing.T, name string) model.Task {
+	t.Helper()
+	var task model.Task
+	if result := core.Db.Where("name = ?", name).Limit(1).Find(&task); result.Error != nil || result.RowsAffected == 0 {
+		t.Fatalf("任务%s不存在: %v", name, result.Error)
+	}
+	return task
+}
+
+func TestUpdateAndRestoreRevision(t *testing.T) {
+	coretest.OpenDb(t)
+	mustCreateTask(t, "build", testPipeline)
+	updated := "steps:\n  - name: test\n    command: make test\n"
+	if err := UpdateTask(model.TaskForm{Name: "build", PipeLine: updated}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if task := taskByName(t, "build"); task.Revision != 2 || task.PipeLine != updated {
+		t.Fatalf("更新后版本为%d, 流水线为%q", task.Revision, task.PipeLine)
+	}
+	// 流水线不变时只更新设置, 不产生新版本
+	if err := UpdateTask(model.TaskForm{Name: "build", Description: "nightly", PipeLine: updated}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if task := taskByName(t, "build"); task.Revision != 2 || task.Description != "nightly" {
+		t.Fatalf("只修改描述后版本为%d, 描述为%q", task.Revision, task.Description)
+	}
+	before, after, err := RestoreRevision("build", 1, "carol", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != updated || after != testPipeline {
+		t.Fatalf("恢复前后的流水线为%q, %q", before, after)
+	}
+	task := taskByName(t, "build")
+	if task.Revision != 3 || task.PipeLine != testPipeline {
+		t.Fatalf("恢复后版本为%d, 流水线为%q", task.Revision, task.PipeLine)
+	}
+	revisions, err := RevisionLists("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 || revisions[0].Author != "carol" {
+		t.Fatalf("修订版本为%+v", revisions)
+	}
+}
+
+func TestUpdateTaskNotFound(t *testing.T) {
+	coretest.OpenDb(t)
+	if err := UpdateTask(model.TaskForm{Name: "missing", PipeLine: testPipeline}, "bob"); err != ErrTaskNotFound {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRunJobUsesStoredTask(t *testing.T) {
+	coretest.OpenDb(t)
+	mustCreateTask(t, "build", testPipeline)
+	if err := CreateTask(model.TaskForm{Name: "lib", Repo: "https://example.com/lib.git", Ref: "refs/heads/main", Forge: core.ForgeGitlab}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := RunJob("build", map[string]string{"V": "1"}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.PipeLine != testPipeline || job.Params["V"] != "1" {
+		t.Errorf("应使用保存的流水线和请求的参数, 实际为%+v", job)
+	}
+	// 修订版本与流水线来自同一行, 之后的修改不影响已生成的构建
+	if err := UpdateTask(model.TaskForm{Name: "build", PipeLine: "steps:\n  - name: test\n    command: make test\n"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if job.Revision != 1 {
+		t.Errorf("构建应对应读取流水线时的版本1, 实际为%d", job.Revision)
+	}
+	if _, err := RunJob("build", map[string]string{"LD_PRELOAD": "/tmp/x.so"}, "", ""); !errors.Is(err, core.ErrInvalidParam) {
+		t.Errorf("参数不能覆盖保留变量, 实际为%v", err)
+	}
+	if _, err := RunJob("build", nil, "dev", ""); !errors.Is(err, ErrRunBranch) {
+		t.Errorf("没有仓库的任务不能指定分支, 实际为%v", err)
+	}
+	job, err = RunJob("lib", nil, "dev", "")
+	if err != nil || job.Ref != "refs/heads/dev" || job.Branch != "dev" {
+		t.Errorf("仓库任务应构建指定的分支, 实际为%+v, %v", job, err)
+	}
+	job, err = RunJob("lib", nil, "", "7")
+	if err != nil || job.Ref != "refs/merge-requests/7/head" || job.ChangeId != "7" {
+		t.Errorf("仓库任务应构建指定的合并请求, 实际为%+v, %v", job, err)
+	}
+
+	if err := TaskDisable("build", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunJob("build", nil, "", ""); !errors.Is(err, ErrTaskDisabled) {
+		t.Errorf("禁用的任务不能运行, 实际为%v", err)
+	}
+	if _, err := RunJob("missing", nil, "", ""); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("不存在的任务不能运行, 实际为%v", err)
+	}
+}
+
+func TestCheckTriggerTargets(t *testing.T) {
+	pipeline := "steps:\n  - name: deploy\n    trigger:\n      task: ops/deploy\n"
+	if err := CheckTriggerTargets(pipeline, func(name string) bool { return name != "ops/deploy" }); !errors.Is(err, core.ErrPermissionDenied) {
+		t.Errorf("没有目标任务运行权限时应拒绝, 实际为%v", err)
+	}
+	if err := CheckTriggerTargets(pipeline, func(string) bool { return true }); err != nil {
+		t.Errorf("有运行权限时应允许, 实际为%v", err)
+	}
+	if err := CheckTriggerTargets(pipeline, nil); err != nil {
+		t.Errorf("命令行不检查权限, 实际为%v", err)
+	}
+}
+
+// 删除任务时一起删除关联数据, 同名任务重新创建后从第1个版本开始且不继承权限和凭据
+func TestDeleteTaskCleansUp(t *testing.T) {
+	coretest.OpenDb(t)
+	mustCreateTask(t, "app", testPipeline)
+	task := taskByName(t, "app")
+	build := model.Build{TaskName: "app", State: core.TaskWaiting}
+	for _, value := range []any{
+		&model.RoleBinding{UserName: "alice", Role: model.RoleMaintainer, Scope: "app"},
+		&model.Credential{CredId: "token", Kind: model.CredentialSecret, Scope: "app"},
+		&model.PollState{TaskId: task.ID, Branch: "main", Sha: "abc"},
+		&build,
+	} {
+		if err := core.Db.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := core.Db.Create(&model.Approval{BuildId: uint64(build.ID), TaskName: "app", State: model.ApprovalPending}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteTask(uint64(task.ID)); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []any{&model.RoleBinding{}, &model.Credential{}, &model.TaskRevision{}, &model.PollState{}, &model.Approval{}} {
+		var count int64
+		core.Db.Model(value).Count(&count)
+		if count != 0 {
+			t.Errorf("删除任务后%T还有%d条", value, count)
+		}
+	}
+	var state string
+	core.Db.Model(&model.Build{}).Where("id = ?", build.ID).Pluck("state", &state)
+	if state != core.TaskCancelled {
+		t.Errorf("等待审批的构建状态为%s", state)
+	}
+	if err := DeleteTask(uint64(task.ID)); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("重复删除时 err = %v", err)
+	}
+
+	mustCreateTask(t, "app", testPipeline)
+	if task := taskByName(t, "app"); task.Revision != 1 {
+		t.Errorf("重新创建的任务版本为%d", task.Revision)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+var (
+	ErrCreateToken = errors.New("创建API令牌失败")
+	ErrDeleteToken = errors.New("吊销API令牌失败")
+	ErrTokenLists  = errors.New("获取API令牌列表失败")
+)
+
+// 创建API令牌, 返回只展示一次的明文
+func CreateApiToken(username string, form model.ApiTokenForm) (*model.ApiTokenRespon, error) {
+	plain, err := core.NewApiToken()
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrCreateToken
+	}
+	token := model.ApiToken{
+		UserName:  username,
+		Name:      form.Name,
+		Prefix:    plain[:len(core.ApiTokenPrefix)+6],
+		TokenHash: core.HashApiToken(plain),
+		Scope:     form.Scope,
+	}
+	if form.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, form.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+	if result := core.Db.Create(&token); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrCreateToken
+	}
+	return &model.ApiTokenRespon{Id: token.ID, Token: plain}, nil
+}
+
+// 吊销当前用户的API令牌
+func DeleteApiToken(username string, id uint64) error {
+	result := core.Db.Where("id = ? AND user_name = ?", id, username).Delete(&model.ApiToken{})
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return ErrDeleteToken
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeleteToken
+	}
+	return nil
+}
+
+func ApiTokenLists(username string) ([]model.ApiToken, error) {
+	var tokens []model.ApiToken
+	if result := core.Db.Where("user_name = ?", username).Order("id").Find(&tokens); result.Error != nil {
+		slog.Error(result.Error.Error())
+		return nil, ErrTokenLists
+	}
+	return tokens, nil
+}
@@ -2,63 +2,245 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 
 	"gookins/core"
 	"gookins/model"
+
+	"gorm.io/gorm"
 )
 
 var (
 	ErrUserForm     = errors.New("用户或密码为空")
 	ErrUserNotFound = errors.New("用户不存在")
-	ErrUserPassword = errors.New("用户密码错误")
 	ErrCreateUser   = errors.New("创建用户失败")
 	ErrDeleteUser   = errors.New("删除用户失败")
 	ErrUpdateUser   = errors.New("更新用户失败")
 	ErrUserLists    = errors.New("获取用户列表失败")
+	ErrUserDisabled = errors.New("用户已禁用")
+	ErrExternalUser = errors.New("同步外部用户失败")
+	ErrUserExists   = errors.New("用户名已存在")
+	// 外部身份与已有的同名用户不是同一来源或同一身份
+	ErrExternalConflict = errors.New("用户名已被其他来源的用户使用")
 )
 
-func UserSign(user model.LoginForm) error {
+// 按认证后端链校验用户名密码, 外部身份源的用户按需创建并同步角色.
+// 返回认证后端确认的用户名, 外部身份源中可能与输入的登录名大小写不同.
+// 所有失败原因都返回同一个错误, 避免枚举用户
+func UserSign(user model.LoginForm) (string, error) {
 	if user.Name == "" || user.Password == "" {
-		return ErrUserForm
+		return "", ErrUserForm
+	}
+	identity, err := core.Authenticate(user.Name, user.Password)
+	if err != nil {
+		return "", core.ErrAuthFailed
+	}
+	if identity.Source != model.UserSourceLocal {
+		name, err := provisionExternalUser(identity.Username, identity.Source, "", identity.Roles)
+		if err != nil {
+			slog.Error(err.Error())
+			return "", core.ErrAuthFailed
+		}
+		return name, nil
 	}
 	var dbUser model.User
-	result := core.Db.Where("name = ?", user.Name).Find(&dbUser)
-	if result.Error != nil {
-		return ErrUserNotFound
+	if result := core.Db.Where("name = ?", identity.Username).Limit(1).Find(&dbUser); result.Error != nil || dbUser.Disabled {
+		return "", core.ErrAuthFailed
 	}
-	if !core.VerifyPassword(dbUser.Password, user.Password) {
-		return ErrUserPassword
+	return dbUser.Name, nil
+}
+
+// 外部身份源(LDAP、OIDC)的用户首次登录时创建, 每次登录以组映射为准重建该来源的角色绑定.
+// subject为身份源中的稳定标识, 不为空时优先按它查找用户, 用户名只在首次登录时使用.
+// 同名的本地用户或其他来源的用户不会被外部身份接管. 返回系统中的用户名
+func provisionExternalUser(username, source, subject string, roles []core.GroupRole) (string, error) {
+	var user model.User
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		found := false
+		if subject != "" {
+			result := tx.Where("source = ? AND subject = ?", source, subject).Limit(1).Find(&user)
+			if result.Error != nil {
+				return result.Error
+			}
+			found = result.RowsAffected > 0
+		}
+		if !found {
+			result := tx.Where("name = ?", username).Limit(1).Find(&user)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				// 迁移前创建的同来源用户没有subject, 首次登录时补上
+				if user.Source != source || (subject != "" && user.Subject != "") {
+					return ErrExternalConflict
+				}
+				if subject != "" {
+					if result := tx.Model(&user).Update("subject", subject); result.Error != nil {
+						return result.Error
+					}
+				}
+			} else {
+				user = model.User{Name: username, Source: source, Subject: subject}
+				if result := tx.Create(&user); result.Error != nil {
+					return result.Error
+				}
+				slog.Info(fmt.Sprintf("创建%s用户: %s", source, username))
+			}
+		}
+		if user.Disabled {
+			return ErrUserDisabled
+		}
+		// 手动创建的绑定不受影响
+		result := tx.Where("user_name = ? AND source = ?", user.Name, source).Delete(&model.RoleBinding{})
+		if result.Error != nil {
+			return result.Error
+		}
+		var bindings []model.RoleBinding
+		for _, role := range roles {
+			bindings = append(bindings, model.RoleBinding{UserName: user.Name, Role: role.Role, Scope: role.Scope, Source: source})
+		}
+		if len(bindings) > 0 {
+			if result := tx.Create(&bindings); result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrUserDisabled) || errors.Is(err, ErrExternalConflict) {
+		return "", err
 	}
-	return nil
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrExternalUser
+	}
+	return user.Name, nil
+}
+
+// 用户名是否已被其他未删除的用户使用
+func userNameTaken(tx *gorm.DB, name string, exceptId string) (bool, error) {
+	query := tx.Model(&model.User{}).Where("name = ?", name)
+	if exceptId != "" {
+		query = query.Where("id <> ?", exceptId)
+	}
+	var count int64
+	if result := query.Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
 }
 
 func CreateUser(user model.UserForm) error {
+	if err := core.CheckPasswordPolicy(user.Password); err != nil {
+		return err
+	}
+	if taken, err := userNameTaken(core.Db, user.Name, ""); err != nil || taken {
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrCreateUser
+		}
+		return ErrUserExists
+	}
+	hashed, err := core.HashPassword(user.Password)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrCreateUser
+	}
 	dbUser := &model.User{
 		Name:     user.Name,
-		Password: core.HashPassword(user.Password),
+		Password: hashed,
 		Avatar:   user.Avatar,
 	}
 	result := core.Db.Create(dbUser)
 	if result.Error != nil {
 		return ErrCreateUser
 	}
+	core.BootstrapAdmin()
 	return nil
 }
 
 func DeleteUser(id uint64) error {
 	var user model.User
-	result := core.Db.Where("id = ?", id).Delete(&user)
-	if result.Error != nil {
-		slog.Error(result.Error.Error())
+	if result := core.Db.Where("id = ?", id).Limit(1).Find(&user); result.Error != nil || result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	// 角色绑定、API令牌和会话按用户名关联, 与用户一起删除, 以免之后同名的新用户继承
+	err := core.Db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("id = ?", id).Delete(&model.User{}); result.Error != nil {
+			return result.Error
+		}
+		for _, value := range []any{&model.RoleBinding{}, &model.ApiToken{}, &model.Session{}} {
+			if result := tx.Where("user_name = ?", user.Name).Delete(value); result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error(err.Error())
 		return ErrDeleteUser
 	}
 	return nil
 }
 
-func UpdateUser(user model.UserForm) error {
-	result := core.Db.Model(&model.User{}).Where("id = ?", user.Id).Updates(model.User{Name: user.Name, Password: core.HashPassword(user.Password), Avatar: user.Avatar})
+// 禁用或启用用户, 禁用时吊销其所有会话
+func UserDisable(id uint64) (bool, error) {
+	var user model.User
+	if result := core.Db.Where("id = ?", id).Limit(1).Find(&user); result.Error != nil || result.RowsAffected == 0 {
+		return false, ErrUserNotFound
+	}
+	result := core.Db.Model(&user).Update("disabled", !user.Disabled)
 	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return false, ErrUpdateUser
+	}
+	if !user.Disabled {
+		core.RevokeUserSessions(user.Name)
+	}
+	return !user.Disabled, nil
+}
+
+func UpdateUser(user model.UserForm) error {
+	if err := core.CheckPasswordPolicy(user.Password); err != nil {
+		return err
+	}
+	hashed, err := core.HashPassword(user.Password)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrUpdateUser
+	}
+	var dbUser model.User
+	if result := core.Db.Where("id = ?", user.Id).Limit(1).Find(&dbUser); result.Error != nil || result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	err = core.Db.Transaction(func(tx *gorm.DB) error {
+		if user.Name != dbUser.Name {
+			taken, err := userNameTaken(tx, user.Name, user.Id)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrUserExists
+			}
+			// 改名时角色绑定和API令牌随用户迁移
+			for _, value := range []any{&model.RoleBinding{}, &model.ApiToken{}} {
+				if result := tx.Model(value).Where("user_name = ?", dbUser.Name).Update("user_name", user.Name); result.Error != nil {
+					return result.Error
+				}
+			}
+		}
+		result := tx.Model(&model.User{}).Where("id = ?", user.Id).Updates(model.User{Name: user.Name, Password: hashed, Avatar: user.Avatar})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 密码变更后旧会话全部失效
+		return tx.Where("user_name = ?", dbUser.Name).Delete(&model.Session{}).Error
+	})
+	if errors.Is(err, ErrUserExists) {
+		return err
+	}
+	if err != nil {
+		slog.Error(err.Error())
 		return ErrUpdateUser
 	}
 	return nil
@@ -66,7 +248,7 @@ func UpdateUser(user model.UserForm) error {
 
 func UserLists() ([]model.User, error) {
 	var users []model.User
-	result := core.Db.Unscoped().Model(&model.User{}).Select("id, created_at, updated_at, deleted_at, name").Find(&users)
+	result := core.Db.Unscoped().Model(&model.User{}).Select("id, created_at, updated_at, deleted_at, name, disabled").Find(&users)
 	if result.Error != nil {
 		return nil, ErrUserLists
 	}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+const testPassword = "gookins-test-pass"
+
+func userByName(t *testing.T, name string) model.User {
+	t.Helper()
+	var user model.User
+	if result := core.Db.Where("name = ?", name).Limit(1).Find(&user); result.Error != nil || result.RowsAffected == 0 {
+		t.Fatalf("用户%s不存在: %v", name, result.Error)
+	}
+	return user
+}
+
+func countByUser(t *testing.T, value any, name string) int64 {
+	t.Helper()
+	var count int64
+	if result := core.Db.Model(value).Where("user_name = ?", name).Count(&count); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	return count
+}
+
+func TestUserNameUnique(t *testing.T) {
+	coretest.OpenDb(t)
+	if err := CreateUser(model.UserForm{Name: "alice", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUser(model.UserForm{Name: "alice", Password: testPassword}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("重名用户应被拒绝, 实际为%v", err)
+	}
+	// 数据库索引同样拒绝重名
+	if result := core.Db.Create(&model.User{Name: "alice"}); result.Error == nil {
+		t.Error("唯一索引应拒绝重名用户")
+	}
+	// 删除后可以重新使用用户名
+	if err := DeleteUser(uint64(userByName(t, "alice").ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUser(model.UserForm{Name: "alice", Password: testPassword}); err != nil {
+		t.Errorf("删除后应可以重新创建同名用户: %v", err)
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	coretest.OpenDb(t)
+	// 第一个用户成为管理员
+	for _, name := range []string{"admin", "bob"} {
+		if err := CreateUser(model.UserForm{Name: name, Password: testPassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	core.Db.Create(&model.RoleBinding{UserName: "bob", Role: model.RoleMaintainer})
+	core.Db.Create(&model.ApiToken{UserName: "bob", TokenHash: "hash"})
+	core.Db.Create(&model.Session{UserName: "bob", RefreshHash: "refresh"})
+
+	if err := DeleteUser(uint64(userByName(t, "bob").ID)); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []any{&model.RoleBinding{}, &model.ApiToken{}, &model.Session{}} {
+		if count := countByUser(t, value, "bob"); count != 0 {
+			t.Errorf("删除用户后%T应一并删除, 剩余%d条", value, count)
+		}
+	}
+	// 之后同名的新用户不继承旧用户的权限
+	if err := CreateUser(model.UserForm{Name: "bob", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if core.HasPermission("bob", core.PermEdit, "app") {
+		t.Error("同名新用户不应继承已删除用户的角色")
+	}
+}
+
+func TestRenameUserMovesBindings(t *testing.T) {
+	coretest.OpenDb(t)
+	for _, name := range []string{"carol", "dave"} {
+		if err := CreateUser(model.UserForm{Name: name, Password: testPassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	core.Db.Create(&model.RoleBinding{UserName: "carol", Role: model.RoleMaintainer, Scope: "app"})
+	core.Db.Create(&model.ApiToken{UserName: "carol", TokenHash: "hash"})
+	core.Db.Create(&model.Session{UserName: "carol", RefreshHash: "refresh"})
+	id := fmt.Sprint(userByName(t, "carol").ID)
+
+	if err := UpdateUser(model.UserForm{Id: id, Name: "dave", Password: testPassword}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("改名为已存在的用户名应被拒绝, 实际为%v", err)
+	}
+	if err := UpdateUser(model.UserForm{Id: id, Name: "erin", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if !core.HasPermission("erin", core.PermEdit, "app") || countByUser(t, &model.ApiToken{}, "erin") != 1 {
+		t.Error("改名后角色绑定和API令牌应随用户迁移")
+	}
+	if countByUser(t, &model.RoleBinding{}, "carol") != 0 || countByUser(t, &model.Session{}, "carol") != 0 {
+		t.Error("改名后旧用户名不应保留绑定或会话")
+	}
+}
+
+func TestProvisionExternalUser(t *testing.T) {
+	coretest.OpenDb(t)
+	if err := CreateUser(model.UserForm{Name: "root", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	roles := []core.GroupRole{{Role: model.RoleDeveloper, Scope: "app"}}
+
+	// 同名的本地用户不能被外部身份接管
+	if _, err := provisionExternalUser("root", model.UserSourceOidc, "idp#1", roles); !errors.Is(err, ErrExternalConflict) {
+		t.Errorf("外部身份不应接管本地用户, 实际为%v", err)
+	}
+	name, err := provisionExternalUser("alice", model.UserSourceOidc, "idp#1", roles)
+	if err != nil || name != "alice" {
+		t.Fatalf("应创建外部用户alice, 实际为%s, %v", name, err)
+	}
+	// IdP中改名后仍然是同一个用户
+	name, err = provisionExternalUser("alice2", model.UserSourceOidc, "idp#1", roles)
+	if err != nil || name != "alice" {
+		t.Errorf("同一sub应登录为原用户, 实际为%s, %v", name, err)
+	}
+	// 重复使用用户名的另一个身份不能登录为alice
+	if _, err := provisionExternalUser("alice", model.UserSourceOidc, "idp#2", roles); !errors.Is(err, ErrExternalConflict) {
+		t.Errorf("不同sub的同名身份应被拒绝, 实际为%v", err)
+	}
+	if _, err := provisionExternalUser("alice", model.UserSourceLdap, "", roles); !errors.Is(err, ErrExternalConflict) {
+		t.Errorf("其他来源的同名身份应被拒绝, 实际为%v", err)
+	}
+	if !core.HasPermission("alice", core.PermRun, "app") || core.HasPermission("alice", core.PermAdmin, "") {
+		t.Error("外部用户应只拥有组映射的角色")
+	}
+}
+
+func TestBootstrapAdminLocalOnly(t *testing.T) {
+	coretest.OpenDb(t)
+	if _, err := provisionExternalUser("alice", model.UserSourceOidc, "idp#1", nil); err != nil {
+		t.Fatal(err)
+	}
+	core.BootstrapAdmin()
+	if core.HasPermission("alice", core.PermAdmin, "") {
+		t.Error("外部用户不应成为初始管理员")
+	}
+	if err := CreateUser(model.UserForm{Name: "root", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if !core.HasPermission("root", core.PermAdmin, "") {
+		t.Error("第一个本地用户应成为初始管理员")
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"gookins/core"
+	"gookins/model"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook不存在")
+	ErrWebhookPayload  = errors.New("webhook请求体不是有效的JSON")
+	ErrWebhookToken    = errors.New("生成webhook令牌失败")
+	ErrTaskDisabled    = errors.New("任务已禁用")
+)
+
+func checkWebhookConfig(task model.TaskForm) error {
+	if task.WebhookParams != "" {
+		var rules map[string]string
+		if err := json.Unmarshal([]byte(task.WebhookParams), &rules); err != nil {
+			return ErrWebhookConfig
+		}
+		// 提取规则的键就是构建参数名
+		if err := core.ValidateParams(rules); err != nil {
+			return err
+		}
+	}
+	if task.WebhookFilter != "" {
+		if _, err := regexp.Compile(task.WebhookFilter); err != nil {
+			return ErrWebhookConfig
+		}
+	}
+	return nil
+}
+
+// 为任务生成新的webhook令牌, 旧令牌随之失效. 令牌只返回这一次, 数据库中保存哈希
+func ResetWebhookToken(name string) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", ErrWebhookToken
+	}
+	token := hex.EncodeToString(buf)
+	result := core.Db.Model(&model.Task{}).Where("name = ?", name).Update("webhook_token", core.HashApiToken(token))
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return "", ErrWebhookToken
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrTaskNotFound
+	}
+	return token, nil
+}
+
+// 按规则从请求头和JSON请求体中提取构建参数
+func extractWebhookParams(rules string, header http.Header, payload any) (map[string]string, error) {
+	params := make(map[string]string)
+	if rules == "" {
+		return params, nil
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(rules), &mapping); err != nil {
+		return nil, ErrWebhookConfig
+	}
+	for name, expr := range mapping {
+		if headerName, ok := strings.CutPrefix(expr, "header:"); ok {
+			params[name] = header.Get(headerName)
+			continue
+		}
+		if value, ok := core.JsonPath(payload, expr); ok {
+			params[name] = core.JsonValueString(value)
+		}
+	}
+	return params, nil
+}
+
+// 处理通用webhook请求, 返回构建ID; 被过滤规则拦截时triggered为false
+func TriggerWebhook(token string, header http.Header, body []byte) (buildId uint64, triggered bool, err error) {
+	if token == "" {
+		return 0, false, ErrWebhookNotFound
+	}
+	var task model.Task
+	result := core.Db.Where("webhook_token = ?", core.HashApiToken(token)).Limit(1).Find(&task)
+	if result.Error != nil {
+		slog.Error(result.Error.Error())
+		return 0, false, ErrWebhookNotFound
+	}
+	if result.RowsAffected == 0 {
+		return 0, false, ErrWebhookNotFound
+	}
+	if task.Disabled {
+		return 0, false, ErrTaskDisabled
+	}
+	var payload any
+	if len(body) > 0 {
+		if payload, err = core.DecodeJson(body); err != nil {
+			return 0, false, ErrWebhookPayload
+		}
+	}
+	params, err := extractWebhookParams(task.WebhookParams, header, payload)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := core.ValidateParams(params); err != nil {
+		return 0, false, err
+	}
+	if task.WebhookFilter != "" {
+		filter, err := regexp.Compile(task.WebhookFilter)
+		if err != nil {
+			return 0, false, ErrWebhookConfig
+		}
+		// 过滤文本为空时匹配原始请求体, 否则匹配展开参数后的文本
+		text := string(body)
+		if task.WebhookFilterText != "" {
+			text = os.Expand(task.WebhookFilterText, func(key string) string { return params[key] })
+		}
+		if !filter.MatchString(text) {
+			return 0, false, nil
+		}
+	}
+	job := core.JobFromTask(&task)
+	job.Params = params
+	buildId, err = core.Tp.AddTask(job)
+	if err != nil {
+		return 0, false, err
+	}
+	return buildId, true, nil
+}
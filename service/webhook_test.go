@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gookins/core"
+	"gookins/core/coretest"
+	"gookins/model"
+)
+
+func TestWebhookToken(t *testing.T) {
+	coretest.OpenDb(t)
+	oldTp, oldSize := core.Tp, core.Config.TaskPoolSize
+	core.Config.TaskPoolSize = 10
+	core.Tp = core.NewTaskPool(context.Background())
+	t.Cleanup(func() { core.Tp, core.Config.TaskPoolSize = oldTp, oldSize })
+	if err := CreateTask(model.TaskForm{Name: "build", PipeLine: testPipeline, WebhookParams: `{"ID": "$.id"}`}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := ResetWebhookToken("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task := taskByName(t, "build"); task.WebhookToken != core.HashApiToken(token) {
+		t.Fatal("数据库中应只保存令牌的哈希")
+	}
+	if _, _, err := TriggerWebhook(core.HashApiToken(token), http.Header{}, nil); err != ErrWebhookNotFound {
+		t.Fatalf("使用哈希触发 err = %v", err)
+	}
+	id, triggered, err := TriggerWebhook(token, http.Header{}, []byte(`{"id": 9007199254740993}`))
+	if err != nil || !triggered {
+		t.Fatalf("触发失败: %v", err)
+	}
+	var build model.Build
+	core.Db.Where("id = ?", id).Find(&build)
+	if build.Params != `{"ID":"9007199254740993"}` {
+		t.Fatalf("构建参数为%s", build.Params)
+	}
+}
+
+func TestWebhookConfigParamNames(t *testing.T) {
+	if err := checkWebhookConfig(model.TaskForm{WebhookParams: `{"REF": "$.ref"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWebhookConfig(model.TaskForm{WebhookParams: `{"PATH": "$.ref"}`}); !errors.Is(err, core.ErrInvalidParam) {
+		t.Fatalf("提取规则覆盖PATH时 err = %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+import request from '@/api/request.js'
+
+
+export const getAuditLogs = (params) => {
+  return request({
+    url: '/audit/list',
+    method: 'get',
+    params
+  })
+}
+
+
+export const exportAuditLogs = (params) => {
+  return request({
+    url: '/audit/export',
+    method: 'get',
+    params,
+    responseType: 'blob'
+  })
+}
@@ -0,0 +1,45 @@
+import request from '@/api/request.js'
+
+
+export const getFolders = (path) => {
+  return request({
+    url: '/folder/list',
+    method: 'get',
+    params: { path }
+  })
+}
+
+
+export const addFolder = (data) => {
+  return request({
+    url: '/folder/add',
+    method: 'post',
+    data
+  })
+}
+
+
+export const updateFolder = (data) => {
+  return request({
+    url: '/folder/upt',
+    method: 'put',
+    data
+  })
+}
+
+
+export const deleteFolder = (path) => {
+  return request({
+    url: `/folder/del/${path}`,
+    method: 'delete'
+  })
+}
+
+
+export const moveFolder = (from, to) => {
+  return request({
+    url: '/folder/move',
+    method: 'post',
+    data: { from, to }
+  })
+}
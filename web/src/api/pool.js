@@ -0,0 +1,66 @@
+import request from '@/api/request.js'
+
+
+export const getPoolInfo = () => {
+  return request({
+    url: '/pool',
+    method: 'get'
+  })
+}
+
+
+export const getPoolQueue = () => {
+  return request({
+    url: '/pool/queue',
+    method: 'get'
+  })
+}
+
+
+export const getPoolWorkers = () => {
+  return request({
+    url: '/pool/workers',
+    method: 'get'
+  })
+}
+
+
+export const removeQueued = (id) => {
+  return request({
+    url: `/pool/queue/${id}`,
+    method: 'delete'
+  })
+}
+
+
+export const pausePool = () => {
+  return request({
+    url: '/pool/pause',
+    method: 'post'
+  })
+}
+
+
+export const resumePool = () => {
+  return request({
+    url: '/pool/resume',
+    method: 'post'
+  })
+}
+
+
+export const drainPool = () => {
+  return request({
+    url: '/pool/drain',
+    method: 'post'
+  })
+}
+
+
+export const resizePool = (data) => {
+  return request({
+    url: '/pool/resize',
+    method: 'put',
+    data
+  })
+}
@@ -7,6 +7,31 @@ const service = axios.create({
   timeout: 50000
 })
 
+// 这些接口返回401时不尝试刷新token
+const noRefreshUrls = ['/login', '/refresh', '/logout']
+
+// 同时过期的多个请求共用一次刷新, 刷新令牌每次使用后都会轮换
+let refreshing = null
+
+const refreshAccessToken = () => {
+  const authStore = useAuthStore()
+  if (!authStore.refreshToken) {
+    return Promise.reject(new Error('no refresh token'))
+  }
+  if (!refreshing) {
+    // 不经过拦截器, 避免刷新失败时递归
+    refreshing = axios.post(service.defaults.baseURL + '/refresh', { refresh_token: authStore.refreshToken })
+      .then((response) => {
+        authStore.setToken(response.data.token, response.data.refresh_token)
+        return response.data.token
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 service.interceptors.request.use((config) => {
   const authStore = useAuthStore()
   if (authStore.token) {
@@ -45,7 +70,19 @@ service.interceptors.response.use((response) => {
   }
   
   return response.data
-}, (error) => {
+}, async (error) => {
+  const config = error.config
+  if (error.response && error.response.status === 401 && config && !config._retried && !noRefreshUrls.includes(config.url)) {
+    // 访问token过期时用刷新令牌换取新token, 然后重试原请求
+    config._retried = true
+    try {
+      const token = await refreshAccessToken()
+      config.headers['Authorization'] = token
+      return service(config)
+    } catch (refreshError) {
+      // 刷新失败按登录过期处理
+    }
+  }
   if (error.response && error.response.status === 401) {
     // Token has expired, handle logout
     const authStore = useAuthStore()
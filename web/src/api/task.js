@@ -65,4 +65,67 @@ export const apiToggleTaskDisabled = (name) => {
     url: `/task/disable/${name}`,
     method: 'post'
   })
-}
\ No newline at end of file
+}
+
+export const getRevisions = (name) => {
+  return request({
+    url: `/task/revisions/${name}`,
+    method: 'get'
+  })
+}
+
+
+export const getRevision = (name, revision) => {
+  return request({
+    url: `/task/revision/${revision}/${name}`,
+    method: 'get'
+  })
+}
+
+
+export const diffRevisions = (name, from, to) => {
+  return request({
+    url: `/task/diff/${name}`,
+    method: 'get',
+    params: { from, to }
+  })
+}
+
+
+export const restoreRevision = (name, revision) => {
+  return request({
+    url: `/task/restore/${revision}/${name}`,
+    method: 'post'
+  })
+}
+
+
+export const moveTask = (from, to) => {
+  return request({
+    url: '/task/move',
+    method: 'post',
+    data: { from, to }
+  })
+}
+
+
+export const exportTasks = (params) => {
+  return request({
+    url: '/task/export',
+    method: 'get',
+    params,
+    responseType: 'blob'
+  })
+}
+
+
+export const importTasks = (file, params) => {
+  const data = new FormData()
+  data.append('file', file)
+  return request({
+    url: '/task/import',
+    method: 'post',
+    params,
+    data
+  })
+}
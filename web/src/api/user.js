@@ -40,4 +40,28 @@ export const deleteUser = (id) => {
     url: `/user/del/${id}`,
     method: 'delete'
   })
-}
\ No newline at end of file
+}
+
+export const refreshToken = (data) => {
+  return request({
+    url: '/refresh',
+    method: 'post',
+    data
+  })
+}
+
+
+export const userLogout = () => {
+  return request({
+    url: '/logout',
+    method: 'post'
+  })
+}
+
+
+export const disableUser = (id) => {
+  return request({
+    url: `/user/disable/${id}`,
+    method: 'post'
+  })
+}
@@ -4,17 +4,25 @@ import { defineStore  } from "pinia"
 export const useAuthStore = defineStore('auth', () => {
 
   const token = ref(localStorage.getItem('token') || '')
+  // 访问token过期后用于换取新token
+  const refreshToken = ref(localStorage.getItem('refresh_token') || '')
 
-  const setToken = (newToken) => {
+  const setToken = (newToken, newRefreshToken) => {
     token.value = newToken
-    localStorage.setItem('token', token)
+    localStorage.setItem('token', newToken)
+    if (newRefreshToken) {
+      refreshToken.value = newRefreshToken
+      localStorage.setItem('refresh_token', newRefreshToken)
+    }
   }
 
   const cleanToken = () => {
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     token.value = ''
+    refreshToken.value = ''
     // localStorage.clear()
   }
 
-  return { token, setToken, cleanToken }
-})
\ No newline at end of file
+  return { token, refreshToken, setToken, cleanToken }
+})